@@ -0,0 +1,148 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"storage_to_git/metrics"
+	"storage_to_git/models"
+)
+
+const (
+	StateIdle    = "idle"
+	StateRunning = "running"
+	StatePaused  = "paused"
+)
+
+var (
+	ErrNotFound = errors.New("project not found")
+	ErrBusy     = errors.New("project is already running")
+)
+
+// ProjectStatus describes the current state of a project.
+type ProjectStatus struct {
+	Name            string            `json:"name"`
+	State           string            `json:"state"`
+	Paused          bool              `json:"paused"`
+	LastError       string            `json:"last_error,omitempty"`
+	LastRunStarted  *time.Time        `json:"last_run_started,omitempty"`
+	LastRunFinished *time.Time        `json:"last_run_finished,omitempty"`
+	NextRun         *time.Time        `json:"next_run,omitempty"`
+	Versions        models.VersionMap `json:"versions"`
+	UnpushedCommits int               `json:"unpushed_commits"`
+	Remotes         []RemoteStatus    `json:"remotes,omitempty"`
+}
+
+// RemoteStatus describes the pushes to a remote of a project since the service started.
+// ProjectStatus.UnpushedCommits is the sum of UnpushedCommits of all remotes.
+type RemoteStatus struct {
+	Name            string     `json:"name"`
+	UnpushedCommits int        `json:"unpushed_commits"`
+	PushFailures    int        `json:"push_failures"`
+	LastPushError   string     `json:"last_push_error,omitempty"`
+	LastPushFailure *time.Time `json:"last_push_failure,omitempty"`
+	NextPushAttempt *time.Time `json:"next_push_attempt,omitempty"`
+}
+
+// Controller gives the API access to the running projects.
+type Controller interface {
+	Projects() []ProjectStatus
+	Project(name string) (ProjectStatus, error)
+	// Trigger starts an immediate run. It returns ErrBusy if the project is already running.
+	Trigger(name string) error
+	Pause(name string) error
+	Resume(name string) error
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// NewServer returns the control API server bound to the given address.
+func NewServer(address string, controller Controller) *http.Server {
+	mux := http.NewServeMux()
+
+	mux.Handle("GET /metrics", metrics.Handler())
+
+	mux.HandleFunc("GET /projects", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, controller.Projects())
+	})
+
+	mux.HandleFunc("GET /projects/{name}", func(w http.ResponseWriter, r *http.Request) {
+		status, err := controller.Project(r.PathValue("name"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, status)
+	})
+
+	mux.HandleFunc("POST /projects/{name}/run", func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		if err := controller.Trigger(name); err != nil {
+			writeError(w, err)
+			return
+		}
+		slog.Info("Run triggered via API", "project", name)
+		writeStatus(w, controller, name, http.StatusAccepted)
+	})
+
+	mux.HandleFunc("POST /projects/{name}/pause", func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		if err := controller.Pause(name); err != nil {
+			writeError(w, err)
+			return
+		}
+		slog.Info("Schedule paused via API", "project", name)
+		writeStatus(w, controller, name, http.StatusOK)
+	})
+
+	mux.HandleFunc("POST /projects/{name}/resume", func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		if err := controller.Resume(name); err != nil {
+			writeError(w, err)
+			return
+		}
+		slog.Info("Schedule resumed via API", "project", name)
+		writeStatus(w, controller, name, http.StatusOK)
+	})
+
+	return &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
+
+func writeStatus(w http.ResponseWriter, controller Controller, name string, code int) {
+	status, err := controller.Project(name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, code, status)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrNotFound):
+		code = http.StatusNotFound
+	case errors.Is(err, ErrBusy):
+		code = http.StatusConflict
+	}
+	writeJSON(w, code, errorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		slog.Error("Failed to write API response", "error", err)
+	}
+}
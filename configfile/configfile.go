@@ -0,0 +1,364 @@
+package configfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"storage_to_git/git"
+	"storage_to_git/models"
+	"storage_to_git/runner"
+	"storage_to_git/schedule"
+	"storage_to_git/secret"
+)
+
+// Problem is a single config error located by the JSON path of the offending value.
+type Problem struct {
+	Path    string
+	Message string
+}
+
+// ValidationError lists all problems found in a config.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		lines[i] = fmt.Sprintf("%s: %s", p.Path, p.Message)
+	}
+	return fmt.Sprintf("%d config problem(s):\n%s", len(e.Problems), strings.Join(lines, "\n"))
+}
+
+// Read parses the config file. Unknown keys are rejected so typos in key names are not ignored.
+func Read(path string) (*models.Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var config models.Config
+	if err := decoder.Decode(&config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	return &config, nil
+}
+
+// Load reads and validates the config file and resolves secret references in passwords.
+// It is used both at startup and when the config file changes.
+func Load(path string) (*models.Config, error) {
+	config, err := Read(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := Validate(config); err != nil {
+		return nil, err
+	}
+	if err := secret.ResolveConfig(config, filepath.Dir(path)); err != nil {
+		return nil, fmt.Errorf("failed to resolve secrets: %w", err)
+	}
+	return config, nil
+}
+
+// Warnings returns settings that are valid but will probably fail on this host.
+func Warnings(config *models.Config) []Problem {
+	var warnings []Problem
+	for i, project := range config.Projects {
+		if project.Backend != models.BackendIbcmd {
+			continue
+		}
+		// The host is checked, not the config: the same config may be valid on another server.
+		if _, err := runner.ReportDisplay(); err != nil {
+			warnings = append(warnings, Problem{Path: fmt.Sprintf("projects[%d].backend", i), Message: err.Error()})
+		}
+	}
+	return warnings
+}
+
+type validator struct {
+	problems []Problem
+}
+
+// add records a problem. Problems with global values shared by several projects are reported once.
+func (v *validator) add(path, format string, args ...any) {
+	problem := Problem{Path: path, Message: fmt.Sprintf(format, args...)}
+	for _, p := range v.problems {
+		if p == problem {
+			return
+		}
+	}
+	v.problems = append(v.problems, problem)
+}
+
+func (v *validator) required(path, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.add(path, "is required")
+	}
+}
+
+func (v *validator) duration(path, value string) {
+	if value == "" {
+		return
+	}
+	if d, err := time.ParseDuration(value); err != nil || d < 0 {
+		v.add(path, "invalid duration %q", value)
+	}
+}
+
+func (v *validator) directory(path, value string) {
+	info, err := os.Stat(value)
+	switch {
+	case err != nil:
+		v.add(path, "directory %q does not exist", value)
+	case !info.IsDir():
+		v.add(path, "%q is not a directory", value)
+	}
+}
+
+var remoteNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_.-]*$`)
+
+// hasPushRemote reports whether pushing the project reaches at least one remote.
+func hasPushRemote(project *models.Project) bool {
+	for _, remote := range project.Remotes() {
+		if remote.Enabled && (remote.PushBranches || remote.PushTags) {
+			return true
+		}
+	}
+	return false
+}
+
+var tagPrefixPattern = regexp.MustCompile(`^[a-zA-Z0-9_./-]*$`)
+
+// tagPrefix checks that a tag prefix yields valid tag names: the label part is sanitized, so only
+// the prefix can break git ref name rules.
+func (v *validator) tagPrefix(path, value string) {
+	if value == "" {
+		return
+	}
+	if !tagPrefixPattern.MatchString(value) || strings.HasPrefix(value, "/") || strings.HasPrefix(value, "-") ||
+		strings.Contains(value, "//") || strings.Contains(value, "..") || strings.Contains(value, "/.") || strings.HasPrefix(value, ".") {
+		v.add(path, "invalid tag prefix %q: use letters, digits, \"_\", \"-\", \".\" and \"/\" separated parts", value)
+	}
+}
+
+// loopbackAddress checks that the control API listens on a loopback address only: the API has
+// no authentication, and its POST endpoints start runs and pause schedules.
+func (v *validator) loopbackAddress(path, value string) {
+	if value == "" {
+		return
+	}
+	host, _, err := net.SplitHostPort(value)
+	if err != nil {
+		v.add(path, "invalid address %q: %v", value, err)
+		return
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		v.add(path, "address %q is not a loopback address: the API has no authentication, use 127.0.0.1, ::1 or localhost", value)
+	}
+}
+
+// dumpTarget is a directory of the git repository the project dumps a configuration into.
+type dumpTarget struct {
+	path string
+	dir  string
+}
+
+// Validate checks the config and returns a ValidationError with every problem found.
+// Paths on disk are checked only for enabled projects.
+func Validate(config *models.Config) error {
+	v := &validator{}
+
+	v.duration("shutdown_timeout", config.ShutdownTimeout)
+	v.loopbackAddress("http_address", config.HTTPAddress)
+
+	names := make(map[string]int)
+	var targets []dumpTarget
+	for i := range config.Projects {
+		project := &config.Projects[i]
+		prefix := fmt.Sprintf("projects[%d]", i)
+
+		v.required(prefix+".project", project.Name)
+		if first, ok := names[project.Name]; ok && project.Name != "" {
+			v.add(prefix+".project", "duplicate project name %q, already used by projects[%d]", project.Name, first)
+		} else {
+			names[project.Name] = i
+		}
+
+		catalog := project.Catalog1cv8
+		catalogPath := prefix + ".catalog_1cv8"
+		if catalog == "" {
+			catalog = config.Catalog1cv8
+			catalogPath = "catalog_1cv8"
+		}
+		v.required(catalogPath, catalog)
+
+		switch project.Backend {
+		case "", models.BackendDesigner, models.BackendIbcmd:
+		default:
+			v.add(prefix+".backend", "unknown backend %q", project.Backend)
+		}
+
+		switch project.GitBackend {
+		case "", git.BackendExec, git.BackendGo:
+		default:
+			v.add(prefix+".git_backend", "unknown git backend %q", project.GitBackend)
+		}
+
+		// A committer is set completely, never mixed with the one from the git config.
+		if c := project.GitCommitter; c != nil && c.Name != "" {
+			v.required(prefix+".git_committer.email", c.Email)
+		} else if c != nil && c.Email != "" {
+			v.required(prefix+".git_committer.name", c.Name)
+		}
+		if signing := project.GitSigning; signing != nil {
+			switch signing.Format {
+			case git.SigningOpenPGP:
+			case git.SigningSSH:
+				if project.GitBackend == git.BackendGo {
+					v.add(prefix+".git_signing.format", "ssh signing is not supported by the go git backend")
+				}
+			default:
+				v.add(prefix+".git_signing.format", "unknown signing format %q", signing.Format)
+			}
+			v.required(prefix+".git_signing.key", signing.Key)
+		}
+
+		v.required(prefix+".project_data_path", project.ProjectDataPath)
+		v.required(prefix+".users_file_path", project.UsersFilePath)
+		v.required(prefix+".versions_file_path", project.VersionsFilePath)
+		v.required(prefix+".v8_log_file_path", project.V8LogFilePath)
+		v.required(prefix+".git_repository_path", project.GitRepositoryPath)
+		v.required(prefix+".branch_name", project.BranchName)
+		if project.GitRemoteUrl != "" && len(project.GitRemotes) > 0 {
+			v.add(prefix+".git_remote_url", "must not be set together with git_remotes")
+		}
+		remoteNames := make(map[string]bool)
+		for j, remote := range project.GitRemotes {
+			remotePrefix := fmt.Sprintf("%s.git_remotes[%d]", prefix, j)
+			if remote.Name == "" {
+				v.add(remotePrefix+".name", "is required")
+			} else if !remoteNamePattern.MatchString(remote.Name) {
+				v.add(remotePrefix+".name", "invalid remote name %q", remote.Name)
+			} else if remoteNames[remote.Name] {
+				v.add(remotePrefix+".name", "duplicate remote %q", remote.Name)
+			}
+			remoteNames[remote.Name] = true
+			v.required(remotePrefix+".url", remote.URL)
+		}
+		if project.GitPushEnabled && !hasPushRemote(project) {
+			v.add(prefix+".git_remotes", "git_push_enabled requires git_remote_url or an enabled remote in git_remotes")
+		}
+
+		if project.ScheduleEnabled {
+			if _, err := schedule.New(project); err != nil {
+				v.add(prefix+".schedule", "%v", err)
+			}
+		}
+
+		ib, err := runner.NewInfobase(project.InfoBase)
+		if err != nil {
+			v.add(prefix+".infobase", "%v", err)
+		}
+		if admin := project.ClusterAdmin; admin != nil && admin.Enabled {
+			if ib != nil && ib.File != "" {
+				v.add(prefix+".cluster_admin.enabled", "cluster administration requires a client-server infobase")
+			}
+			if admin.LockSessions && admin.PermissionCode == "" {
+				v.add(prefix+".cluster_admin.permission_code", "is required when lock_sessions is set")
+			}
+		}
+
+		if messages, err := runner.NewCommitMessages(project); err != nil {
+			v.add(prefix+".commit_message", "%v", err)
+		} else {
+			// Templates fail on unknown fields only when executed, so render a sample of both kinds.
+			for _, comment := range []string{"comment", ""} {
+				if _, _, err := messages.Render(models.ReportVersion{Version: "1", Comment: comment}); err != nil {
+					v.add(prefix+".commit_message", "%v", err)
+				}
+			}
+		}
+
+		if project.Timeouts != nil {
+			v.duration(prefix+".timeouts.default", project.Timeouts.Default)
+			v.duration(prefix+".timeouts.report", project.Timeouts.Report)
+			v.duration(prefix+".timeouts.unbind", project.Timeouts.Unbind)
+			v.duration(prefix+".timeouts.update", project.Timeouts.Update)
+			v.duration(prefix+".timeouts.dump", project.Timeouts.Dump)
+			v.duration(prefix+".timeouts.rac", project.Timeouts.Rac)
+		}
+
+		if project.Storage == nil && len(project.Extensions) == 0 {
+			v.add(prefix, "storage or extensions must be specified")
+		}
+		if project.Storage != nil {
+			v.required(prefix+".storage.storage_path", project.Storage.StoragePath)
+			v.required(prefix+".storage.storage_user", project.Storage.StorageUser)
+			v.tagPrefix(prefix+".storage.tag_prefix", project.Storage.TagPrefix)
+			targets = append(targets, dumpTarget{
+				path: prefix + ".storage.git_repository_path",
+				dir:  filepath.Join(project.GitRepositoryPath, project.Storage.GitRepositoryPath),
+			})
+		}
+
+		extensionNames := make(map[string]int)
+		for j, ext := range project.Extensions {
+			extPrefix := fmt.Sprintf("%s.extensions[%d]", prefix, j)
+			v.required(extPrefix+".extension_name", ext.ExtensionName)
+			if first, ok := extensionNames[ext.ExtensionName]; ok && ext.ExtensionName != "" {
+				v.add(extPrefix+".extension_name", "duplicate extension name %q, already used by %s.extensions[%d]", ext.ExtensionName, prefix, first)
+			} else {
+				extensionNames[ext.ExtensionName] = j
+			}
+			v.required(extPrefix+".storage_path", ext.StoragePath)
+			v.required(extPrefix+".storage_user", ext.StorageUser)
+			v.tagPrefix(extPrefix+".tag_prefix", ext.TagPrefix)
+			targets = append(targets, dumpTarget{
+				path: extPrefix + ".git_repository_path",
+				dir:  filepath.Join(project.GitRepositoryPath, ext.GitRepositoryPath, ext.ExtensionName),
+			})
+		}
+
+		if project.Enabled {
+			if catalog != "" {
+				v.directory(catalogPath, catalog)
+			}
+			if project.ProjectDataPath != "" {
+				v.directory(prefix+".project_data_path", project.ProjectDataPath)
+			}
+		}
+	}
+
+	// Every configuration is dumped with a full sync of its directory, so a directory
+	// inside another one would be wiped by the other dump.
+	for i := range targets {
+		for j := i + 1; j < len(targets); j++ {
+			if pathContains(targets[i].dir, targets[j].dir) || pathContains(targets[j].dir, targets[i].dir) {
+				v.add(targets[j].path, "directory %q overlaps with %s (%q)", targets[j].dir, targets[i].path, targets[i].dir)
+			}
+		}
+	}
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+// pathContains reports whether child is parent itself or lies inside it.
+func pathContains(parent, child string) bool {
+	rel, err := filepath.Rel(filepath.Clean(parent), filepath.Clean(child))
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
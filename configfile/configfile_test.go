@@ -0,0 +1,151 @@
+package configfile
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"storage_to_git/models"
+)
+
+// validProject returns an enabled project that passes validation with directories under dir.
+func validProject(name, dir string) models.Project {
+	return models.Project{
+		Name:              name,
+		Enabled:           true,
+		Schedule:          "1h",
+		ScheduleEnabled:   true,
+		ProjectDataPath:   dir,
+		UsersFilePath:     filepath.Join(dir, "users.json"),
+		VersionsFilePath:  filepath.Join(dir, "versions.json"),
+		V8LogFilePath:     filepath.Join(dir, "v8.log"),
+		GitRepositoryPath: filepath.Join(dir, name),
+		BranchName:        "master",
+		InfoBase:          models.InfoBase{InfoBaseFile: filepath.Join(dir, "ib")},
+		Storage:           &models.Storage{StoragePath: "tcp://server/" + name, StorageUser: "admin", GitRepositoryPath: "cf"},
+	}
+}
+
+func problemPaths(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Validate returned %T, want *ValidationError: %v", err, err)
+	}
+	paths := make([]string, len(validationErr.Problems))
+	for i, p := range validationErr.Problems {
+		paths[i] = p.Path
+	}
+	return paths
+}
+
+func TestValidateAcceptsValidConfig(t *testing.T) {
+	dir := t.TempDir()
+	config := &models.Config{Catalog1cv8: dir, Projects: []models.Project{validProject("erp", dir), validProject("hrm", dir)}}
+	if err := Validate(config); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateReportsAllProblems(t *testing.T) {
+	dir := t.TempDir()
+
+	erp := validProject("erp", dir)
+	erp.Schedule = "every hour"
+	erp.Extensions = []models.Extension{
+		{ExtensionName: "Sales", StoragePath: "tcp://server/sales", StorageUser: "admin", GitRepositoryPath: "cfe"},
+		{ExtensionName: "Sales", StoragePath: "tcp://server/sales2", StorageUser: "admin", GitRepositoryPath: "cfe"},
+	}
+
+	duplicate := validProject("erp", dir)
+	duplicate.GitRepositoryPath = filepath.Join(dir, "other")
+
+	// The configuration of this project is dumped into the configuration directory of erp.
+	overlapping := validProject("hrm", dir)
+	overlapping.GitRepositoryPath = filepath.Join(dir, "erp")
+
+	config := &models.Config{Catalog1cv8: dir, ShutdownTimeout: "soon", Projects: []models.Project{erp, duplicate, overlapping}}
+	got := problemPaths(t, Validate(config))
+	want := []string{
+		"shutdown_timeout",
+		"projects[0].schedule",
+		"projects[0].extensions[1].extension_name",
+		"projects[1].project",
+		"projects[2].storage.git_repository_path",
+		// The duplicate extension is dumped into the same directory as the first one.
+		"projects[0].extensions[1].git_repository_path",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("problems at\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestValidateChecksPathsOfEnabledProjectsOnly(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing")
+
+	enabled := validProject("erp", dir)
+	enabled.Catalog1cv8 = missing
+	enabled.ProjectDataPath = missing
+
+	disabled := validProject("hrm", dir)
+	disabled.Enabled = false
+	disabled.Catalog1cv8 = missing
+	disabled.ProjectDataPath = missing
+	// Schedules of disabled projects are checked, since they only need to be enabled to run.
+	disabled.Schedule = ""
+
+	config := &models.Config{Projects: []models.Project{enabled, disabled}}
+	got := problemPaths(t, Validate(config))
+	want := []string{"projects[0].catalog_1cv8", "projects[0].project_data_path", "projects[1].schedule"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("problems at %q, want %q", got, want)
+	}
+}
+
+func TestValidateClusterAdmin(t *testing.T) {
+	dir := t.TempDir()
+
+	fileInfobase := validProject("erp", dir)
+	fileInfobase.ClusterAdmin = &models.ClusterAdmin{Enabled: true, TerminateSessions: true}
+
+	withoutCode := validProject("hrm", dir)
+	withoutCode.InfoBase = models.InfoBase{InfoBaseServer: "srv1c", InfoBaseRef: "hrm"}
+	withoutCode.ClusterAdmin = &models.ClusterAdmin{Enabled: true, LockSessions: true}
+
+	// Settings of a disabled cluster administration are not used.
+	disabled := validProject("crm", dir)
+	disabled.ClusterAdmin = &models.ClusterAdmin{LockSessions: true}
+
+	config := &models.Config{Catalog1cv8: dir, Projects: []models.Project{fileInfobase, withoutCode, disabled}}
+	got := problemPaths(t, Validate(config))
+	want := []string{"projects[0].cluster_admin.enabled", "projects[1].cluster_admin.permission_code"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("problems at %q, want %q", got, want)
+	}
+}
+
+func TestValidateHTTPAddress(t *testing.T) {
+	for address, valid := range map[string]bool{
+		"":               true,
+		"127.0.0.1:8080": true,
+		"127.0.0.2:8080": true,
+		"[::1]:8080":     true,
+		"localhost:8080": true,
+		":8080":          false,
+		"0.0.0.0:8080":   false,
+		"[::]:8080":      false,
+		"10.0.0.5:8080":  false,
+		"server:8080":    false,
+		"127.0.0.1":      false,
+	} {
+		err := Validate(&models.Config{HTTPAddress: address})
+		if got := problemPaths(t, err); (len(got) == 0) != valid {
+			t.Errorf("http_address %q: problems at %q, want valid = %v", address, got, valid)
+		}
+	}
+}
@@ -1,60 +1,60 @@
 package git
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/exec"
-	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 )
 
-type Repository struct {
+// ExecRepository runs the git executable installed on the host.
+type ExecRepository struct {
 	Path      string
 	RemoteUrl string
+	Settings  Settings
 }
 
-func NewRepository(logger *slog.Logger, path, remoteUrl string) (*Repository, error) {
-	repo := &Repository{
-		Path:      path,
-		RemoteUrl: remoteUrl,
-	}
+// command returns a git command run in the repository. Messages are forced to English because
+// some of them are matched below.
+func (r *ExecRepository) command(args ...string) *exec.Cmd {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.Path
+	cmd.Env = append(os.Environ(), "LC_ALL=C", "LANGUAGE=C")
+	return cmd
+}
 
-	gitDir := filepath.Join(path, ".git")
-	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
-		logger.Info("Git repository not found, initializing...", "path", path)
-		if err := os.MkdirAll(path, os.ModePerm); err != nil {
-			return nil, fmt.Errorf("failed to create repository directory: %w", err)
-		}
+// signedCommand returns a git command with the signing key configured. The key is passed to
+// git as user.signingkey, so the key id, key file or agent key are looked up the way git does.
+func (r *ExecRepository) signedCommand(args ...string) *exec.Cmd {
+	return r.command(append([]string{"-c", "gpg.format=" + r.Settings.SigningFormat, "-c", "user.signingkey=" + r.Settings.SigningKey}, args...)...)
+}
 
-		initCmd := exec.Command("git", "init")
-		initCmd.Dir = path
-		if err := initCmd.Run(); err != nil {
-			return nil, fmt.Errorf("git init failed: %w", err)
-		}
+func (r *ExecRepository) init(logger *slog.Logger) error {
+	initCmd := r.command("init")
+	if err := initCmd.Run(); err != nil {
+		return fmt.Errorf("git init failed: %w", err)
+	}
 
-		if remoteUrl != "" {
-			logger.Info("Adding remote origin", "url", remoteUrl)
-			remoteCmd := exec.Command("git", "remote", "add", "origin", remoteUrl)
-			remoteCmd.Dir = path
-			if err := remoteCmd.Run(); err != nil {
-				logger.Warn("git remote add failed (maybe remote already exists?)", "error", err)
-			}
+	if r.RemoteUrl != "" {
+		logger.Info("Adding remote origin", "url", r.RemoteUrl)
+		remoteCmd := r.command("remote", "add", "origin", r.RemoteUrl)
+		if err := remoteCmd.Run(); err != nil {
+			logger.Warn("git remote add failed (maybe remote already exists?)", "error", err)
 		}
 	}
-
-	return repo, nil
+	return nil
 }
 
-func (r *Repository) GetCurrentBranch() (string, error) {
-	cmd := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
-	cmd.Dir = r.Path
+func (r *ExecRepository) GetCurrentBranch() (string, error) {
+	cmd := r.command("rev-parse", "--abbrev-ref", "HEAD")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		if strings.Contains(string(output), "unknown revision or path not in the working tree") {
-			cmd := exec.Command("git", "symbolic-ref", "--short", "HEAD")
-			cmd.Dir = r.Path
+			cmd := r.command("symbolic-ref", "--short", "HEAD")
 			output, err := cmd.CombinedOutput()
 			if err != nil {
 				return "", fmt.Errorf("failed to get current branch with symbolic-ref after rev-parse failed: %w, output: %s", err, string(output))
@@ -66,11 +66,16 @@ func (r *Repository) GetCurrentBranch() (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
-func (r *Repository) Checkout(logger *slog.Logger, branchName string) error {
+func (r *ExecRepository) Checkout(logger *slog.Logger, branchName string) error {
 	logger.Info("Switching to branch", "branch", branchName, "repo", r.Path)
 
-	checkoutCmd := exec.Command("git", "checkout", branchName)
-	checkoutCmd.Dir = r.Path
+	// A branch without commits, e.g. a new orphan branch, cannot be checked out but may be current.
+	if current, err := r.GetCurrentBranch(); err == nil && current == branchName {
+		logger.Info("Already on branch", "branch", branchName)
+		return nil
+	}
+
+	checkoutCmd := r.command("checkout", branchName)
 	output, err := checkoutCmd.CombinedOutput()
 
 	if err == nil {
@@ -80,8 +85,7 @@ func (r *Repository) Checkout(logger *slog.Logger, branchName string) error {
 
 	logger.Info("Failed to checkout branch, attempting to create it", "branch", branchName, "error", err, "output", string(output))
 
-	createCmd := exec.Command("git", "switch", "-c", branchName)
-	createCmd.Dir = r.Path
+	createCmd := r.command("switch", "-c", branchName)
 	output, err = createCmd.CombinedOutput()
 
 	if err != nil {
@@ -92,17 +96,15 @@ func (r *Repository) Checkout(logger *slog.Logger, branchName string) error {
 	return nil
 }
 
-func (r *Repository) Commit(logger *slog.Logger, authorName, authorEmail, message string, commitDate time.Time) (bool, error) {
+func (r *ExecRepository) Commit(logger *slog.Logger, authorName, authorEmail, message string, commitDate time.Time) (bool, error) {
 
-	addCmd := exec.Command("git", "add", ".")
-	addCmd.Dir = r.Path
+	addCmd := r.command("add", ".")
 	output, err := addCmd.CombinedOutput()
 	if err != nil {
 		return false, fmt.Errorf("git add failed: %w, output: %s", err, string(output))
 	}
 
-	statusCmd := exec.Command("git", "status", "--porcelain")
-	statusCmd.Dir = r.Path
+	statusCmd := r.command("status", "--porcelain")
 	output, err = statusCmd.CombinedOutput()
 	if err != nil {
 		return false, fmt.Errorf("git status failed: %w, output: %s", err, string(output))
@@ -115,8 +117,19 @@ func (r *Repository) Commit(logger *slog.Logger, authorName, authorEmail, messag
 	author := fmt.Sprintf("%s <%s>", authorName, authorEmail)
 	commitDateStr := commitDate.Format(time.RFC3339)
 
-	commitCmd := exec.Command("git", "commit", "-m", message, "--author", author, "--date", commitDateStr)
-	commitCmd.Dir = r.Path
+	commitCmd := r.command("commit", "-m", message, "--author", author, "--date", commitDateStr)
+	if r.Settings.SigningFormat != "" {
+		commitCmd = r.signedCommand("commit", "-S", "-m", message, "--author", author, "--date", commitDateStr)
+	}
+	if r.Settings.CommitterName != "" {
+		commitCmd.Env = append(commitCmd.Env, "GIT_COMMITTER_NAME="+r.Settings.CommitterName)
+	}
+	if r.Settings.CommitterEmail != "" {
+		commitCmd.Env = append(commitCmd.Env, "GIT_COMMITTER_EMAIL="+r.Settings.CommitterEmail)
+	}
+	if r.Settings.CommitterDateFromAuthor {
+		commitCmd.Env = append(commitCmd.Env, "GIT_COMMITTER_DATE="+commitDateStr)
+	}
 	output, err = commitCmd.CombinedOutput()
 
 	if err != nil {
@@ -131,10 +144,28 @@ func (r *Repository) Commit(logger *slog.Logger, authorName, authorEmail, messag
 	return true, nil
 }
 
-func (r *Repository) Push(logger *slog.Logger, remote, branch string) error {
+func (r *ExecRepository) SetRemote(logger *slog.Logger, name, url string) error {
+	output, err := r.command("config", "--get", "remote."+name+".url").Output()
+	current := strings.TrimSpace(string(output))
+	switch {
+	case err != nil:
+		logger.Info("Adding remote", "repo", r.Path, "remote", name, "url", url)
+		output, err = r.command("remote", "add", name, url).CombinedOutput()
+	case current != url:
+		logger.Info("Changing remote URL", "repo", r.Path, "remote", name, "old_url", current, "url", url)
+		output, err = r.command("remote", "set-url", name, url).CombinedOutput()
+	default:
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set remote %s: %w, output: %s", name, err, string(output))
+	}
+	return nil
+}
+
+func (r *ExecRepository) Push(logger *slog.Logger, remote, branch string) error {
 	logger.Info("Pushing to remote", "repo", r.Path, "remote", remote, "branch", branch)
-	pushCmd := exec.Command("git", "push", remote, branch)
-	pushCmd.Dir = r.Path
+	pushCmd := r.command("push", remote, branch)
 	output, err := pushCmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("git push failed: %w, output: %s", err, string(output))
@@ -143,10 +174,20 @@ func (r *Repository) Push(logger *slog.Logger, remote, branch string) error {
 	return nil
 }
 
-func (r *Repository) Tag(logger *slog.Logger, tagName string) error {
+func (r *ExecRepository) Tag(logger *slog.Logger, tagName, message, taggerName, taggerEmail string, tagDate time.Time) error {
 	logger.Info("Creating git tag", "repo", r.Path, "tag", tagName)
-	tagCmd := exec.Command("git", "tag", tagName)
-	tagCmd.Dir = r.Path
+	tagCmd := r.command("tag", tagName)
+	if message != "" && r.Settings.SigningFormat != "" {
+		tagCmd = r.signedCommand("tag", "-s", tagName, "-m", message)
+	} else if message != "" {
+		tagCmd = r.command("tag", "-a", tagName, "-m", message)
+	}
+	if taggerName != "" {
+		tagCmd.Env = append(tagCmd.Env, "GIT_COMMITTER_NAME="+taggerName, "GIT_COMMITTER_EMAIL="+taggerEmail)
+	}
+	if !tagDate.IsZero() {
+		tagCmd.Env = append(tagCmd.Env, "GIT_COMMITTER_DATE="+tagDate.Format(time.RFC3339))
+	}
 	output, err := tagCmd.CombinedOutput()
 	if err != nil {
 		// git tag returns status 1 if tag already exists
@@ -160,10 +201,9 @@ func (r *Repository) Tag(logger *slog.Logger, tagName string) error {
 	return nil
 }
 
-func (r *Repository) PushTags(logger *slog.Logger, remote string) error {
+func (r *ExecRepository) PushTags(logger *slog.Logger, remote string) error {
 	logger.Info("Pushing tags to remote", "repo", r.Path, "remote", remote)
-	pushCmd := exec.Command("git", "push", remote, "--tags")
-	pushCmd.Dir = r.Path
+	pushCmd := r.command("push", remote, "--tags")
 	output, err := pushCmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("git push --tags failed: %w, output: %s", err, string(output))
@@ -171,3 +211,112 @@ func (r *Repository) PushTags(logger *slog.Logger, remote string) error {
 	logger.Info("Git push tags successful", "output", string(output))
 	return nil
 }
+
+func (r *ExecRepository) Unpushed(remote, branch string) (int, error) {
+	ref := "refs/heads/" + branch
+	if exists, err := r.commitExists(ref); err != nil || !exists {
+		return 0, err
+	}
+	args := []string{"rev-list", "--count", ref}
+	remoteRef := "refs/remotes/" + remote + "/" + branch
+	exists, err := r.commitExists(remoteRef)
+	if err != nil {
+		return 0, err
+	}
+	if exists {
+		args = append(args, "^"+remoteRef)
+	}
+	output, err := r.command(args...).Output()
+	if err != nil {
+		return 0, fmt.Errorf("git rev-list failed: %w", err)
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(string(output)))
+	if err != nil {
+		return 0, fmt.Errorf("unexpected git rev-list output %q", output)
+	}
+	return count, nil
+}
+
+// commitExists reports whether ref names a commit. Only a missing ref is reported as false;
+// any other failure of git, e.g. a broken repository, is an error.
+func (r *ExecRepository) commitExists(ref string) (bool, error) {
+	output, err := r.command("rev-parse", "--verify", "--quiet", ref+"^{commit}").CombinedOutput()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("git rev-parse %s failed: %w, output: %s", ref, err, string(output))
+	}
+	return true, nil
+}
+
+func (r *ExecRepository) Log(ref string, keys ...string) ([]Commit, error) {
+	format := "%H %T%n%(trailers:only,unfold"
+	for _, key := range keys {
+		format += ",key=" + key
+	}
+	format += ")%x1e"
+
+	if exists, err := r.commitExists(ref); err != nil || !exists {
+		return nil, err
+	}
+
+	logCmd := r.command("log", "--format="+format, ref)
+	output, err := logCmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git log failed: %w", err)
+	}
+
+	var commits []Commit
+	for _, block := range strings.Split(string(output), "\x1e") {
+		lines := strings.Split(strings.TrimLeft(block, "\n"), "\n")
+		hash, tree, found := strings.Cut(lines[0], " ")
+		if !found {
+			continue
+		}
+		commit := Commit{Hash: hash, Tree: tree, Trailers: make(map[string]string)}
+		for _, line := range lines[1:] {
+			key, value, found := strings.Cut(line, ":")
+			if found {
+				commit.Trailers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+			}
+		}
+		commits = append(commits, commit)
+	}
+	return commits, nil
+}
+
+func (r *ExecRepository) CreateOrphanBranch(logger *slog.Logger, branchName string) error {
+	logger.Info("Creating branch without history", "branch", branchName, "repo", r.Path)
+	output, err := r.command("checkout", "--orphan", branchName).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to create branch '%s': %w, output: %s", branchName, err, string(output))
+	}
+	output, err = r.command("rm", "-r", "-f", "-q", "--ignore-unmatch", ".").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to clear working tree: %w, output: %s", err, string(output))
+	}
+	return nil
+}
+
+func (r *ExecRepository) IsClean() (bool, error) {
+	output, err := r.command("status", "--porcelain").CombinedOutput()
+	if err != nil {
+		return false, fmt.Errorf("git status failed: %w, output: %s", err, string(output))
+	}
+	return len(strings.TrimSpace(string(output))) == 0, nil
+}
+
+func (r *ExecRepository) DiscardChanges(logger *slog.Logger) error {
+	logger.Info("Discarding changes in working tree", "repo", r.Path)
+	output, err := r.command("reset", "--hard", "--quiet").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to reset working tree: %w, output: %s", err, string(output))
+	}
+	output, err = r.command("clean", "-f", "-d", "-q").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to remove untracked files: %w, output: %s", err, string(output))
+	}
+	return nil
+}
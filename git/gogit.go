@@ -0,0 +1,554 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+)
+
+// GoRepository works with the repository in process, without the git executable.
+// SSH remotes authenticate with the ssh agent or, without one, the default key files in ~/.ssh;
+// HTTPS remotes with the credentials in the remote URL. Only OpenPGP signing is supported.
+type GoRepository struct {
+	Path      string
+	RemoteUrl string
+	Settings  Settings
+
+	signKey *openpgp.Entity
+}
+
+func newGoRepository(path, remoteUrl string, settings Settings) (*GoRepository, error) {
+	r := &GoRepository{Path: path, RemoteUrl: remoteUrl, Settings: settings}
+	switch settings.SigningFormat {
+	case "":
+	case SigningOpenPGP:
+		key, err := readSigningKey(settings.SigningKey, settings.SigningPassphrase)
+		if err != nil {
+			return nil, err
+		}
+		r.signKey = key
+	default:
+		return nil, fmt.Errorf("signing format %q is not supported by the go git backend", settings.SigningFormat)
+	}
+	return r, nil
+}
+
+// readSigningKey reads the first key of an armored OpenPGP key file and decrypts it.
+func readSigningKey(keyFile, passphrase string) (*openpgp.Entity, error) {
+	file, err := os.Open(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+	defer file.Close()
+
+	keys, err := openpgp.ReadArmoredKeyRing(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key %s: %w", keyFile, err)
+	}
+	key := keys[0]
+	if key.PrivateKey == nil {
+		return nil, fmt.Errorf("signing key %s has no private key", keyFile)
+	}
+	if key.PrivateKey.Encrypted {
+		if err := key.DecryptPrivateKeys([]byte(passphrase)); err != nil {
+			return nil, fmt.Errorf("failed to decrypt signing key %s: %w", keyFile, err)
+		}
+	}
+	return key, nil
+}
+
+func (r *GoRepository) open() (*gogit.Repository, error) {
+	repo, err := gogit.PlainOpen(r.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open git repository %s: %w", r.Path, err)
+	}
+	return repo, nil
+}
+
+func (r *GoRepository) init(logger *slog.Logger) error {
+	repo, err := gogit.PlainInit(r.Path, false)
+	if err != nil {
+		return fmt.Errorf("git init failed: %w", err)
+	}
+
+	if r.RemoteUrl != "" {
+		logger.Info("Adding remote origin", "url", r.RemoteUrl)
+		if _, err := repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{r.RemoteUrl}}); err != nil {
+			logger.Warn("git remote add failed (maybe remote already exists?)", "error", err)
+		}
+	}
+	return nil
+}
+
+func (r *GoRepository) GetCurrentBranch() (string, error) {
+	repo, err := r.open()
+	if err != nil {
+		return "", err
+	}
+	// HEAD of a branch without commits points to a reference that does not exist yet.
+	head, err := repo.Reference(plumbing.HEAD, false)
+	if err != nil {
+		return "", fmt.Errorf("failed to get current branch: %w", err)
+	}
+	if head.Type() == plumbing.SymbolicReference {
+		return head.Target().Short(), nil
+	}
+	return "HEAD", nil
+}
+
+func (r *GoRepository) Checkout(logger *slog.Logger, branchName string) error {
+	logger.Info("Switching to branch", "branch", branchName, "repo", r.Path)
+
+	// A branch without commits, e.g. a new orphan branch, cannot be checked out but may be current.
+	if current, err := r.GetCurrentBranch(); err == nil && current == branchName {
+		logger.Info("Already on branch", "branch", branchName)
+		return nil
+	}
+
+	repo, err := r.open()
+	if err != nil {
+		return err
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+
+	branch := plumbing.NewBranchReferenceName(branchName)
+	if _, err := repo.Reference(branch, false); err == nil {
+		if err := worktree.Checkout(&gogit.CheckoutOptions{Branch: branch, Keep: true}); err != nil {
+			return fmt.Errorf("failed to checkout branch '%s': %w", branchName, err)
+		}
+		logger.Info("Switched to existing branch", "branch", branchName)
+		return nil
+	}
+
+	logger.Info("Branch not found, creating it", "branch", branchName)
+
+	// Like git checkout, start the branch from the remote branch of the same name if there is one.
+	var start plumbing.Hash
+	if remote, err := repo.Reference(plumbing.NewRemoteReferenceName("origin", branchName), true); err == nil {
+		start = remote.Hash()
+	} else if head, err := repo.Head(); err == nil {
+		start = head.Hash()
+	}
+
+	if start.IsZero() {
+		// Without commits there is nothing to check out, only HEAD is moved.
+		if err := repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, branch)); err != nil {
+			return fmt.Errorf("failed to create and switch to branch '%s': %w", branchName, err)
+		}
+	} else if err := worktree.Checkout(&gogit.CheckoutOptions{Branch: branch, Hash: start, Create: true, Keep: true}); err != nil {
+		return fmt.Errorf("failed to create and switch to branch '%s': %w", branchName, err)
+	}
+
+	logger.Info("Created and switched to new branch", "branch", branchName)
+	return nil
+}
+
+func (r *GoRepository) Commit(logger *slog.Logger, authorName, authorEmail, message string, commitDate time.Time) (bool, error) {
+	repo, err := r.open()
+	if err != nil {
+		return false, err
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return false, err
+	}
+
+	if err := worktree.AddWithOptions(&gogit.AddOptions{All: true}); err != nil {
+		return false, fmt.Errorf("git add failed: %w", err)
+	}
+
+	status, err := worktree.Status()
+	if err != nil {
+		return false, fmt.Errorf("git status failed: %w", err)
+	}
+	if status.IsClean() {
+		logger.Warn("git commit: nothing to commit")
+		return false, nil
+	}
+
+	author := &object.Signature{Name: authorName, Email: authorEmail, When: commitDate}
+	committer := configSignature(repo, author)
+	if r.Settings.CommitterName != "" {
+		committer.Name = r.Settings.CommitterName
+	}
+	if r.Settings.CommitterEmail != "" {
+		committer.Email = r.Settings.CommitterEmail
+	}
+	if r.Settings.CommitterDateFromAuthor {
+		committer.When = commitDate
+	}
+	_, err = worktree.Commit(message, &gogit.CommitOptions{
+		Author:    author,
+		Committer: committer,
+		SignKey:   r.signKey,
+	})
+	if err != nil {
+		return false, fmt.Errorf("git commit failed: %w", err)
+	}
+
+	logger.Info("Git commit successful", "output", "")
+	return true, nil
+}
+
+// configSignature returns the user from the git config with the current time, as git uses for
+// the committer, falling back to the given signature.
+func configSignature(repo *gogit.Repository, fallback *object.Signature) *object.Signature {
+	cfg, err := repo.ConfigScoped(config.SystemScope)
+	if err != nil || cfg.User.Name == "" {
+		return &object.Signature{Name: fallback.Name, Email: fallback.Email, When: time.Now()}
+	}
+	return &object.Signature{Name: cfg.User.Name, Email: cfg.User.Email, When: time.Now()}
+}
+
+func (r *GoRepository) SetRemote(logger *slog.Logger, name, url string) error {
+	repo, err := r.open()
+	if err != nil {
+		return err
+	}
+	cfg, err := repo.Config()
+	if err != nil {
+		return fmt.Errorf("failed to read git config: %w", err)
+	}
+	remote, ok := cfg.Remotes[name]
+	switch {
+	case !ok:
+		logger.Info("Adding remote", "repo", r.Path, "remote", name, "url", url)
+		if _, err := repo.CreateRemote(&config.RemoteConfig{Name: name, URLs: []string{url}}); err != nil {
+			return fmt.Errorf("failed to add remote %s: %w", name, err)
+		}
+		return nil
+	case len(remote.URLs) == 1 && remote.URLs[0] == url:
+		return nil
+	}
+	logger.Info("Changing remote URL", "repo", r.Path, "remote", name, "old_url", strings.Join(remote.URLs, " "), "url", url)
+	remote.URLs = []string{url}
+	if err := repo.SetConfig(cfg); err != nil {
+		return fmt.Errorf("failed to set remote %s: %w", name, err)
+	}
+	return nil
+}
+
+func (r *GoRepository) Push(logger *slog.Logger, remote, branch string) error {
+	logger.Info("Pushing to remote", "repo", r.Path, "remote", remote, "branch", branch)
+	refSpec := config.RefSpec(fmt.Sprintf("refs/heads/%s:refs/heads/%s", branch, branch))
+	if err := r.push(remote, refSpec); err != nil {
+		return fmt.Errorf("git push failed: %w", err)
+	}
+	logger.Info("Git push successful")
+	return nil
+}
+
+func (r *GoRepository) Tag(logger *slog.Logger, tagName, message, taggerName, taggerEmail string, tagDate time.Time) error {
+	logger.Info("Creating git tag", "repo", r.Path, "tag", tagName)
+
+	repo, err := r.open()
+	if err != nil {
+		return err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return fmt.Errorf("git tag failed: %w", err)
+	}
+
+	var opts *gogit.CreateTagOptions
+	if message != "" {
+		opts = &gogit.CreateTagOptions{Message: message, SignKey: r.signKey}
+		if taggerName != "" {
+			if tagDate.IsZero() {
+				tagDate = time.Now()
+			}
+			opts.Tagger = &object.Signature{Name: taggerName, Email: taggerEmail, When: tagDate}
+		} else {
+			opts.Tagger = configSignature(repo, &object.Signature{})
+			if !tagDate.IsZero() {
+				opts.Tagger.When = tagDate
+			}
+		}
+	}
+
+	if _, err := repo.CreateTag(tagName, head.Hash(), opts); err != nil {
+		if errors.Is(err, gogit.ErrTagExists) {
+			logger.Warn("git tag already exists", "tag", tagName)
+			return nil
+		}
+		return fmt.Errorf("git tag failed: %w", err)
+	}
+	logger.Info("Git tag successful")
+	return nil
+}
+
+func (r *GoRepository) PushTags(logger *slog.Logger, remote string) error {
+	logger.Info("Pushing tags to remote", "repo", r.Path, "remote", remote)
+	if err := r.push(remote, "refs/tags/*:refs/tags/*"); err != nil {
+		return fmt.Errorf("git push --tags failed: %w", err)
+	}
+	logger.Info("Git push tags successful")
+	return nil
+}
+
+func (r *GoRepository) push(remote string, refSpec config.RefSpec) error {
+	repo, err := r.open()
+	if err != nil {
+		return err
+	}
+	auth, err := r.auth(repo, remote)
+	if err != nil {
+		return err
+	}
+	err = repo.Push(&gogit.PushOptions{RemoteName: remote, RefSpecs: []config.RefSpec{refSpec}, Auth: auth})
+	if errors.Is(err, gogit.NoErrAlreadyUpToDate) {
+		return nil
+	}
+	return err
+}
+
+// auth returns the credentials for an SSH remote when there is no ssh agent to provide them.
+// Other protocols use the credentials in the URL.
+func (r *GoRepository) auth(repo *gogit.Repository, remote string) (transport.AuthMethod, error) {
+	rem, err := repo.Remote(remote)
+	if err != nil {
+		return nil, err
+	}
+	urls := rem.Config().URLs
+	if len(urls) == 0 {
+		return nil, nil
+	}
+	endpoint, err := transport.NewEndpoint(urls[0])
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.Protocol != "ssh" || os.Getenv("SSH_AUTH_SOCK") != "" {
+		return nil, nil
+	}
+
+	user := endpoint.User
+	if user == "" {
+		user = ssh.DefaultUsername
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, nil
+	}
+	for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+		keyFile := filepath.Join(home, ".ssh", name)
+		if _, err := os.Stat(keyFile); err == nil {
+			return ssh.NewPublicKeysFromFile(user, keyFile, "")
+		}
+	}
+	return nil, nil
+}
+
+func (r *GoRepository) Unpushed(remote, branch string) (int, error) {
+	repo, err := r.open()
+	if err != nil {
+		return 0, err
+	}
+	head, err := repo.Reference(plumbing.NewBranchReferenceName(branch), true)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve branch %s: %w", branch, err)
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return 0, fmt.Errorf("failed to read commit %s: %w", head.Hash(), err)
+	}
+
+	// Commits reachable from the remote-tracking branch are pushed.
+	pushed := make(map[plumbing.Hash]bool)
+	tracking, err := repo.Reference(plumbing.NewRemoteReferenceName(remote, branch), true)
+	switch {
+	case errors.Is(err, plumbing.ErrReferenceNotFound):
+	case err != nil:
+		return 0, fmt.Errorf("failed to resolve remote-tracking branch %s/%s: %w", remote, branch, err)
+	default:
+		remoteCommit, err := repo.CommitObject(tracking.Hash())
+		if err != nil {
+			return 0, fmt.Errorf("failed to read commit %s: %w", tracking.Hash(), err)
+		}
+		err = object.NewCommitPreorderIter(remoteCommit, nil, nil).ForEach(func(c *object.Commit) error {
+			pushed[c.Hash] = true
+			return nil
+		})
+		if err != nil {
+			return 0, fmt.Errorf("git log failed: %w", err)
+		}
+	}
+
+	count := 0
+	err = object.NewCommitPreorderIter(commit, pushed, nil).ForEach(func(*object.Commit) error {
+		count++
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("git log failed: %w", err)
+	}
+	return count, nil
+}
+
+func (r *GoRepository) Log(ref string, keys ...string) ([]Commit, error) {
+	repo, err := r.open()
+	if err != nil {
+		return nil, err
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(ref))
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", ref, err)
+	}
+
+	iter, err := repo.Log(&gogit.LogOptions{From: *hash})
+	if err != nil {
+		return nil, fmt.Errorf("git log failed: %w", err)
+	}
+	defer iter.Close()
+
+	var commits []Commit
+	err = iter.ForEach(func(commit *object.Commit) error {
+		commits = append(commits, Commit{
+			Hash:     commit.Hash.String(),
+			Tree:     commit.TreeHash.String(),
+			Trailers: messageTrailers(commit.Message, keys),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("git log failed: %w", err)
+	}
+	return commits, nil
+}
+
+func (r *GoRepository) CreateOrphanBranch(logger *slog.Logger, branchName string) error {
+	logger.Info("Creating branch without history", "branch", branchName, "repo", r.Path)
+
+	repo, err := r.open()
+	if err != nil {
+		return err
+	}
+	branch := plumbing.NewBranchReferenceName(branchName)
+	if _, err := repo.Reference(branch, false); err == nil {
+		return fmt.Errorf("failed to create branch '%s': branch already exists", branchName)
+	}
+	if err := repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, branch)); err != nil {
+		return fmt.Errorf("failed to create branch '%s': %w", branchName, err)
+	}
+	return r.removeTracked(repo)
+}
+
+// removeTracked removes the files in the index from the working tree and empties the index.
+func (r *GoRepository) removeTracked(repo *gogit.Repository) error {
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		return err
+	}
+	for _, entry := range idx.Entries {
+		if err := os.Remove(filepath.Join(r.Path, filepath.FromSlash(entry.Name))); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to clear working tree: %w", err)
+		}
+	}
+	idx.Entries = nil
+	idx.Cache = nil
+	idx.ResolveUndo = nil
+	if err := repo.Storer.SetIndex(idx); err != nil {
+		return fmt.Errorf("failed to clear index: %w", err)
+	}
+	return nil
+}
+
+func (r *GoRepository) IsClean() (bool, error) {
+	repo, err := r.open()
+	if err != nil {
+		return false, err
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return false, err
+	}
+	status, err := worktree.Status()
+	if err != nil {
+		return false, fmt.Errorf("git status failed: %w", err)
+	}
+	return status.IsClean(), nil
+}
+
+func (r *GoRepository) DiscardChanges(logger *slog.Logger) error {
+	logger.Info("Discarding changes in working tree", "repo", r.Path)
+
+	repo, err := r.open()
+	if err != nil {
+		return err
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+
+	head, err := repo.Head()
+	switch {
+	case errors.Is(err, plumbing.ErrReferenceNotFound):
+		if err := r.removeTracked(repo); err != nil {
+			return err
+		}
+	case err != nil:
+		return fmt.Errorf("failed to resolve HEAD: %w", err)
+	default:
+		if err := worktree.Reset(&gogit.ResetOptions{Commit: head.Hash(), Mode: gogit.HardReset}); err != nil {
+			return fmt.Errorf("failed to reset working tree: %w", err)
+		}
+	}
+
+	if err := worktree.Clean(&gogit.CleanOptions{Dir: true}); err != nil {
+		return fmt.Errorf("failed to remove untracked files: %w", err)
+	}
+	return nil
+}
+
+// messageTrailers returns the trailers with the given keys from the last paragraph of a commit
+// message, as git does: the subject paragraph never holds trailers and folded lines are joined.
+func messageTrailers(message string, keys []string) map[string]string {
+	trailers := make(map[string]string)
+	paragraphs := strings.Split(strings.TrimSpace(strings.ReplaceAll(message, "\r\n", "\n")), "\n\n")
+	if len(paragraphs) < 2 {
+		return trailers
+	}
+
+	var lines []string
+	for _, line := range strings.Split(paragraphs[len(paragraphs)-1], "\n") {
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += " " + strings.TrimSpace(line)
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	for _, line := range lines {
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		for _, k := range keys {
+			if strings.EqualFold(strings.TrimSpace(key), k) {
+				trailers[k] = strings.TrimSpace(value)
+			}
+		}
+	}
+	return trailers
+}
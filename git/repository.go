@@ -0,0 +1,109 @@
+package git
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Backends implementing Repository, selected by the git_backend project setting.
+const (
+	BackendExec = "exec"
+	BackendGo   = "go"
+)
+
+// Repository is a git working tree the storage versions are committed to.
+type Repository interface {
+	GetCurrentBranch() (string, error)
+	// Checkout switches to the branch, creating it if it does not exist.
+	Checkout(logger *slog.Logger, branchName string) error
+	// Commit stages all changes and commits them. It returns false if there was nothing to commit.
+	Commit(logger *slog.Logger, authorName, authorEmail, message string, commitDate time.Time) (bool, error)
+	// Tag tags HEAD. An empty message creates a lightweight tag, otherwise the tag is annotated
+	// and the tagger and the tag date are taken from the arguments; an empty tagger name keeps
+	// the git config. An existing tag is not an error.
+	Tag(logger *slog.Logger, tagName, message, taggerName, taggerEmail string, tagDate time.Time) error
+	// SetRemote adds the remote or changes its URL to the given one.
+	SetRemote(logger *slog.Logger, name, url string) error
+	Push(logger *slog.Logger, remote, branch string) error
+	PushTags(logger *slog.Logger, remote string) error
+	// Unpushed returns the number of commits of the branch that the remote-tracking branch of
+	// the remote does not contain, i.e. all of them if the branch was never pushed there.
+	Unpushed(remote, branch string) (int, error)
+	// Log returns every commit reachable from ref, newest first, with the values of the given
+	// trailer keys. A ref without commits yields no commits.
+	Log(ref string, keys ...string) ([]Commit, error)
+	// CreateOrphanBranch switches to a new branch without history and removes the tracked files
+	// from the index and the working tree. The branch must not exist.
+	CreateOrphanBranch(logger *slog.Logger, branchName string) error
+	// IsClean reports whether the working tree has neither uncommitted changes nor untracked files.
+	IsClean() (bool, error)
+	// DiscardChanges resets the index and the working tree to HEAD, or to an empty tree on a
+	// branch without commits, and removes untracked files.
+	DiscardChanges(logger *slog.Logger) error
+
+	init(logger *slog.Logger) error
+}
+
+// Commit is a commit returned by Repository.Log.
+type Commit struct {
+	Hash     string
+	Tree     string
+	Trailers map[string]string
+}
+
+// Signing formats.
+const (
+	SigningOpenPGP = "openpgp"
+	SigningSSH     = "ssh"
+)
+
+// Settings are the project options applied to commits and tags.
+type Settings struct {
+	// CommitterName and CommitterEmail each replace the value from the git config when set.
+	CommitterName  string
+	CommitterEmail string
+	// CommitterDateFromAuthor sets the committer date to the author date instead of the current time.
+	CommitterDateFromAuthor bool
+
+	// SigningFormat enables signing of commits and annotated tags with SigningKey.
+	SigningFormat     string
+	SigningKey        string
+	SigningPassphrase string
+}
+
+// OpenRepository returns the repository at path without creating it.
+func OpenRepository(backend, path, remoteUrl string, settings Settings) (Repository, error) {
+	switch backend {
+	case "", BackendExec:
+		return &ExecRepository{Path: path, RemoteUrl: remoteUrl, Settings: settings}, nil
+	case BackendGo:
+		return newGoRepository(path, remoteUrl, settings)
+	default:
+		return nil, fmt.Errorf("unknown git backend %q", backend)
+	}
+}
+
+// NewRepository returns the repository at path, initializing it with the origin remote
+// if it does not exist.
+func NewRepository(logger *slog.Logger, backend, path, remoteUrl string, settings Settings) (Repository, error) {
+	repo, err := OpenRepository(backend, path, remoteUrl, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	gitDir := filepath.Join(path, ".git")
+	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
+		logger.Info("Git repository not found, initializing...", "path", path)
+		if err := os.MkdirAll(path, os.ModePerm); err != nil {
+			return nil, fmt.Errorf("failed to create repository directory: %w", err)
+		}
+		if err := repo.init(logger); err != nil {
+			return nil, err
+		}
+	}
+
+	return repo, nil
+}
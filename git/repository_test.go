@@ -0,0 +1,245 @@
+package git
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// backendResult is everything the shared steps observe in a repository, so the results of the
+// backends can be compared as a whole.
+type backendResult struct {
+	Branch          string
+	Commits         []Commit
+	EmptyCommit     bool
+	CleanStates     []bool
+	Tags            map[string]string
+	TagTypes        map[string]string
+	UnpushedBefore  int
+	UnpushedAfter   int
+	UnpushedMissing int
+	RemoteRefs      map[string]string
+}
+
+func TestBackendsBehaveAlike(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	// Keep the user and system git config out of the exec backend.
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	tests := []struct {
+		name    string
+		backend string
+	}{
+		{"exec", BackendExec},
+		{"go", BackendGo},
+	}
+
+	results := make(map[string]backendResult)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results[tt.name] = runBackendSteps(t, tt.backend)
+		})
+	}
+
+	if !reflect.DeepEqual(results["exec"], results["go"]) {
+		t.Errorf("backends differ:\nexec: %+v\ngo:   %+v", results["exec"], results["go"])
+	}
+}
+
+func runBackendSteps(t *testing.T, backend string) backendResult {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	dir := t.TempDir()
+	path := filepath.Join(dir, "repo")
+	bare := filepath.Join(dir, "remote.git")
+	gitCommand(t, dir, "init", "--quiet", "--bare", bare)
+
+	settings := Settings{CommitterName: "Converter", CommitterEmail: "converter@example.com", CommitterDateFromAuthor: true}
+	repo, err := NewRepository(logger, backend, path, bare, settings)
+	if err != nil {
+		t.Fatalf("NewRepository: %v", err)
+	}
+	if err := repo.Checkout(logger, "main"); err != nil {
+		t.Fatalf("Checkout: %v", err)
+	}
+
+	var result backendResult
+	if result.Branch, err = repo.GetCurrentBranch(); err != nil {
+		t.Fatalf("GetCurrentBranch: %v", err)
+	}
+	if commits, err := repo.Log("main"); err != nil || commits != nil {
+		t.Fatalf("Log of an unborn branch = %v, %v; want no commits", commits, err)
+	}
+
+	date := time.Date(2024, 3, 1, 10, 30, 0, 0, time.FixedZone("MSK", 3*60*60))
+	writeFile(t, filepath.Join(path, "src", "cf", "Configuration.xml"), "<v1/>")
+	if ok, err := repo.Commit(logger, "Ivanov", "ivanov@example.com", "First version\n\nStorage-Version: 1\n", date); err != nil || !ok {
+		t.Fatalf("Commit 1 = %v, %v", ok, err)
+	}
+	if err := repo.Tag(logger, "v1", "", "", "", time.Time{}); err != nil {
+		t.Fatalf("lightweight Tag: %v", err)
+	}
+
+	writeFile(t, filepath.Join(path, "src", "cf", "Configuration.xml"), "<v2/>")
+	writeFile(t, filepath.Join(path, "src", "cf", "Module.bsl"), "Процедура Тест()\nКонецПроцедуры\n")
+	if ok, err := repo.Commit(logger, "Petrov", "petrov@example.com", "Second version\n\nStorage-Version: 2\nStorage-User: petrov\n", date.Add(time.Hour)); err != nil || !ok {
+		t.Fatalf("Commit 2 = %v, %v", ok, err)
+	}
+	if err := repo.Tag(logger, "cf/release-2", "Release two", "Petrov", "petrov@example.com", date.Add(time.Hour)); err != nil {
+		t.Fatalf("annotated Tag: %v", err)
+	}
+	if err := repo.Tag(logger, "cf/release-2", "Release two", "Petrov", "petrov@example.com", date.Add(time.Hour)); err != nil {
+		t.Fatalf("existing Tag must not fail: %v", err)
+	}
+	if result.EmptyCommit, err = repo.Commit(logger, "Petrov", "petrov@example.com", "Nothing", date); err != nil {
+		t.Fatalf("empty Commit: %v", err)
+	}
+
+	isClean := func() {
+		t.Helper()
+		clean, err := repo.IsClean()
+		if err != nil {
+			t.Fatalf("IsClean: %v", err)
+		}
+		result.CleanStates = append(result.CleanStates, clean)
+	}
+	isClean()
+	writeFile(t, filepath.Join(path, "src", "cf", "Configuration.xml"), "<changed/>")
+	writeFile(t, filepath.Join(path, "src", "ext", "Untracked.xml"), "<new/>")
+	isClean()
+	if err := repo.DiscardChanges(logger); err != nil {
+		t.Fatalf("DiscardChanges: %v", err)
+	}
+	isClean()
+	if data, err := os.ReadFile(filepath.Join(path, "src", "cf", "Configuration.xml")); err != nil || string(data) != "<v2/>" {
+		t.Errorf("Configuration.xml after DiscardChanges = %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(path, "src", "ext")); !os.IsNotExist(err) {
+		t.Errorf("untracked directory kept by DiscardChanges: %v", err)
+	}
+
+	if result.Commits, err = repo.Log("main", "Storage-Version", "Storage-User"); err != nil {
+		t.Fatalf("Log: %v", err)
+	}
+	result.Tags = refs(t, path, "refs/tags")
+	result.TagTypes = make(map[string]string)
+	for name := range result.Tags {
+		result.TagTypes[name] = strings.TrimSpace(gitCommand(t, path, "cat-file", "-t", name))
+	}
+
+	if result.UnpushedBefore, err = repo.Unpushed("origin", "main"); err != nil {
+		t.Fatalf("Unpushed: %v", err)
+	}
+	if err := repo.Push(logger, "origin", "main"); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if err := repo.PushTags(logger, "origin"); err != nil {
+		t.Fatalf("PushTags: %v", err)
+	}
+	if result.UnpushedAfter, err = repo.Unpushed("origin", "main"); err != nil {
+		t.Fatalf("Unpushed after push: %v", err)
+	}
+	if result.UnpushedMissing, err = repo.Unpushed("origin", "missing"); err != nil {
+		t.Fatalf("Unpushed of a missing branch: %v", err)
+	}
+	result.RemoteRefs = refs(t, bare, "refs")
+
+	if len(result.Commits) != 2 || result.Commits[0].Trailers["Storage-Version"] != "2" || result.Commits[0].Trailers["Storage-User"] != "petrov" ||
+		result.Commits[1].Trailers["Storage-Version"] != "1" {
+		t.Errorf("Log = %+v", result.Commits)
+	}
+	if result.Branch != "main" || result.EmptyCommit || result.UnpushedBefore != 2 || result.UnpushedAfter != 0 || result.UnpushedMissing != 0 {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if !reflect.DeepEqual(result.CleanStates, []bool{true, false, true}) {
+		t.Errorf("IsClean before and after changes and after DiscardChanges = %v", result.CleanStates)
+	}
+	if result.TagTypes["v1"] != "commit" || result.TagTypes["cf/release-2"] != "tag" {
+		t.Errorf("tag types = %v", result.TagTypes)
+	}
+	if result.RemoteRefs["refs/heads/main"] != result.Commits[0].Hash || len(result.RemoteRefs) != 3 {
+		t.Errorf("remote refs = %v", result.RemoteRefs)
+	}
+	return result
+}
+
+func gitCommand(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v, output: %s", strings.Join(args, " "), err, output)
+	}
+	return string(output)
+}
+
+// refs returns the references below prefix with the objects they point to.
+func refs(t *testing.T, dir, prefix string) map[string]string {
+	t.Helper()
+	result := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(gitCommand(t, dir, "for-each-ref", "--format=%(refname) %(objectname)", prefix)), "\n") {
+		if name, hash, ok := strings.Cut(line, " "); ok {
+			result[strings.TrimPrefix(name, "refs/tags/")] = hash
+		}
+	}
+	return result
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLogMissingRefAndBrokenRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	for _, backend := range []string{BackendExec, BackendGo} {
+		t.Run(backend, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Setenv("GIT_CEILING_DIRECTORIES", dir)
+
+			path := filepath.Join(dir, "repo")
+			repo, err := NewRepository(logger, backend, path, "", Settings{})
+			if err != nil {
+				t.Fatalf("NewRepository: %v", err)
+			}
+			if commits, err := repo.Log("missing"); err != nil || commits != nil {
+				t.Errorf("Log of a missing ref = %v, %v; want no commits and no error", commits, err)
+			}
+			if count, err := repo.Unpushed("origin", "missing"); err != nil || count != 0 {
+				t.Errorf("Unpushed of a missing branch = %d, %v", count, err)
+			}
+
+			// A repository whose git directory is gone must not look like an empty history.
+			if err := os.RemoveAll(filepath.Join(path, ".git")); err != nil {
+				t.Fatal(err)
+			}
+			if commits, err := repo.Log("main"); err == nil {
+				t.Errorf("Log of a broken repository = %v, nil; want an error", commits)
+			}
+			if _, err := repo.Unpushed("origin", "main"); err == nil {
+				t.Error("Unpushed of a broken repository: want an error")
+			}
+		})
+	}
+}
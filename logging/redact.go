@@ -0,0 +1,126 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mask replaces secret values in log records.
+const Mask = "******"
+
+var (
+	mu       sync.RWMutex
+	secrets  = make(map[string]struct{})
+	replacer = strings.NewReplacer()
+)
+
+// AddSecrets registers values that must never appear in log records. Secrets are only added,
+// never removed, so values from a previous configuration stay redacted after a reload.
+func AddSecrets(values ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	changed := false
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+		if _, ok := secrets[value]; !ok {
+			secrets[value] = struct{}{}
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+
+	// Longer secrets go first so a secret containing another one is masked as a whole.
+	sorted := make([]string, 0, len(secrets))
+	for value := range secrets {
+		sorted = append(sorted, value)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+
+	pairs := make([]string, 0, len(sorted)*2)
+	for _, value := range sorted {
+		pairs = append(pairs, value, Mask)
+	}
+	replacer = strings.NewReplacer(pairs...)
+}
+
+// Redact replaces all registered secrets in s with Mask.
+func Redact(s string) string {
+	mu.RLock()
+	r := replacer
+	mu.RUnlock()
+	return r.Replace(s)
+}
+
+// Handler masks registered secrets in the message and attributes of every record
+// before passing it to the wrapped handler.
+type Handler struct {
+	next slog.Handler
+}
+
+func NewHandler(next slog.Handler) *Handler {
+	return &Handler{next: next}
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *Handler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, Redact(record.Message), record.PC)
+	record.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(redactAttr(a))
+		return true
+	})
+	return h.next.Handle(ctx, redacted)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{next: h.next.WithAttrs(redactAttrs(attrs))}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{next: h.next.WithGroup(name)}
+}
+
+func redactAttrs(attrs []slog.Attr) []slog.Attr {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = redactAttr(a)
+	}
+	return redacted
+}
+
+func redactAttr(a slog.Attr) slog.Attr {
+	value := a.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(Redact(value.String()))
+	case slog.KindGroup:
+		a.Value = slog.GroupValue(redactAttrs(value.Group())...)
+	case slog.KindAny:
+		switch v := value.Any().(type) {
+		case error:
+			a.Value = slog.StringValue(Redact(v.Error()))
+		default:
+			// Arbitrary values are replaced by their text form only if they contain a secret.
+			text := fmt.Sprintf("%+v", v)
+			if masked := Redact(text); masked != text {
+				a.Value = slog.StringValue(masked)
+			} else {
+				a.Value = value
+			}
+		}
+	default:
+		a.Value = value
+	}
+	return a
+}
@@ -2,18 +2,30 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"sort"
+	"storage_to_git/api"
+	"storage_to_git/configfile"
+	"storage_to_git/logging"
+	"storage_to_git/metrics"
 	"storage_to_git/models"
 	"storage_to_git/runner"
+	"storage_to_git/schedule"
+	"storage_to_git/secret"
 	"storage_to_git/storage"
+	"storage_to_git/workspace"
 	"strings"
 	"sync"
+	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -21,15 +33,43 @@ import (
 
 var (
 	projectCancelFuncs    = make(map[string]context.CancelFunc)
+	projectStopFuncs      = make(map[string]context.CancelFunc)
+	projectSettings       = make(map[string]models.Project)
+	projectStates         = make(map[string]*projectState)
 	projectExecutionLocks = make(map[string]*sync.Mutex)
 	projectMutex          sync.Mutex
+	projectRuns           sync.WaitGroup
+	shuttingDown          bool
 )
 
+const defaultShutdownTimeout = 5 * time.Minute
+
+// projectState holds the current instance of a project and the status reported by the control API.
+type projectState struct {
+	ctx     context.Context
+	config  *models.Config
+	project *models.Project
+
+	running         bool
+	paused          bool
+	lastError       string
+	lastRunStarted  time.Time
+	lastRunFinished time.Time
+	nextRun         time.Time
+	pushes          *runner.PushState
+}
+
 var version = "development"
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "run" {
+		os.Exit(runCommand(os.Args[2:]))
+	}
+
 	versionFlag := flag.Bool("version", false, "Print application version and exit")
 	configFile := flag.String("config", "", "Path to the configuration file")
+	validateFlag := flag.Bool("validate", false, "Validate the configuration file and exit")
+	keystoreSet := flag.String("keystore-set", "", "Read a secret from stdin, store it in the keystore under this name and exit")
 	flag.Parse()
 
 	if *versionFlag {
@@ -37,36 +77,38 @@ func main() {
 		os.Exit(0)
 	}
 
-	var configPath string
-	if *configFile != "" {
-		configPath = *configFile
-	} else {
-		exePath, err := os.Executable()
+	configPath, err := resolveConfigPath(*configFile)
+	if err != nil {
+		println("Failed to get executable path: " + err.Error())
+		os.Exit(1)
+	}
+
+	if *keystoreSet != "" {
+		config, err := configfile.Read(configPath)
 		if err != nil {
-			println("Failed to get executable path: " + err.Error())
+			println(err.Error())
 			os.Exit(1)
 		}
-		configPath = filepath.Join(filepath.Dir(exePath), "config.json")
-	}
-
-	jsonFile, err := os.Open(configPath)
-	if err != nil {
-		println("Failed to open config file: " + err.Error())
-		os.Exit(1)
+		if err := setKeystoreSecret(config, configPath, *keystoreSet); err != nil {
+			println("Failed to update keystore: " + err.Error())
+			os.Exit(1)
+		}
+		fmt.Printf("Secret %q saved, use \"keystore:%s\" in the config\n", *keystoreSet, *keystoreSet)
+		os.Exit(0)
 	}
 
-	byteValue, err := io.ReadAll(jsonFile)
+	config, err := configfile.Load(configPath)
 	if err != nil {
-		println("Failed to read config file: " + err.Error())
+		println("Invalid config: " + err.Error())
 		os.Exit(1)
 	}
-	jsonFile.Close()
 
-	var config models.Config
-	err = json.Unmarshal(byteValue, &config)
-	if err != nil {
-		println("Failed to unmarshal config JSON: " + err.Error())
-		os.Exit(1)
+	if *validateFlag {
+		for _, warning := range configfile.Warnings(config) {
+			fmt.Printf("Warning: %s: %s\n", warning.Path, warning.Message)
+		}
+		fmt.Println("Config is valid")
+		os.Exit(0)
 	}
 
 	if !filepath.IsAbs(config.AppLogDir) {
@@ -110,13 +152,27 @@ func main() {
 		return a
 	}
 
-	logger := slog.New(slog.NewJSONHandler(logFile, &slog.HandlerOptions{
+	logging.AddSecrets(config.Secrets()...)
+	logger := slog.New(logging.NewHandler(slog.NewJSONHandler(logFile, &slog.HandlerOptions{
 		Level:       logLevel,
 		ReplaceAttr: replaceAttr,
-	}))
+	})))
 	slog.SetDefault(logger)
+	logConfigWarnings(config)
+
+	shutdownTimeout := defaultShutdownTimeout
+	if config.ShutdownTimeout != "" {
+		shutdownTimeout, err = time.ParseDuration(config.ShutdownTimeout)
+		if err != nil {
+			slog.Error("Invalid shutdown timeout", "shutdown_timeout", config.ShutdownTimeout, "error", err)
+			os.Exit(1)
+		}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
 
-	updateProjects(&config)
+	updateProjects(config)
 
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
@@ -134,27 +190,14 @@ func main() {
 				}
 				if event.Has(fsnotify.Write) {
 					slog.Info("Config file modified. Reloading projects...")
-					// Re-read config and update projects
-					jsonFile, err := os.Open(configPath)
+					newConfig, err := configfile.Load(configPath)
 					if err != nil {
-						slog.Error("Failed to open config file", "error", err)
+						slog.Error("Invalid config, keeping current projects", "error", err)
 						continue
 					}
-					byteValue, err := io.ReadAll(jsonFile)
-					if err != nil {
-						slog.Error("Failed to read config file", "error", err)
-						jsonFile.Close()
-						continue
-					}
-					jsonFile.Close()
-
-					var newConfig models.Config
-					err = json.Unmarshal(byteValue, &newConfig)
-					if err != nil {
-						slog.Error("Failed to unmarshal config JSON", "error", err)
-						continue
-					}
-					updateProjects(&newConfig)
+					logging.AddSecrets(newConfig.Secrets()...)
+					logConfigWarnings(newConfig)
+					updateProjects(newConfig)
 				}
 			case err, ok := <-watcher.Errors:
 				if !ok {
@@ -171,14 +214,370 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Block main goroutine forever.
-	<-make(chan struct{})
+	var apiServer *http.Server
+	if config.HTTPAddress != "" {
+		apiServer = api.NewServer(config.HTTPAddress, projectController{})
+		go func() {
+			slog.Info("Starting control API", "address", config.HTTPAddress)
+			if err := apiServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("Control API failed", "error", err)
+			}
+		}()
+	}
+
+	sig := <-signals
+	slog.Info("Received signal, shutting down", "signal", sig.String(), "timeout", shutdownTimeout.String())
+	code := shutdown(signals, shutdownTimeout)
+	if apiServer != nil {
+		apiServer.Close()
+	}
+	os.Exit(code)
+}
+
+// logConfigWarnings logs the settings that are valid but will probably not work as intended.
+func logConfigWarnings(config *models.Config) {
+	for _, warning := range configfile.Warnings(config) {
+		slog.Warn("Config warning", "path", warning.Path, "warning", warning.Message)
+	}
+}
+
+// resolveConfigPath returns the config path from the flag or config.json next to the executable.
+func resolveConfigPath(configFile string) (string, error) {
+	if configFile != "" {
+		return configFile, nil
+	}
+	exePath, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(exePath), "config.json"), nil
+}
+
+// runCommand processes a single project once without the config watcher and scheduler,
+// logging progress to stdout. It returns the process exit code.
+func runCommand(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	configFile := flags.String("config", "", "Path to the configuration file")
+	projectName := flags.String("project", "", "Name of the project to run")
+	dryRun := flags.Bool("dry-run", false, "Print the planned commits without changing the infobase or git")
+	rebuild := flags.Bool("rebuild", false, "Convert all versions again into a new branch and compare the trees with the project branch")
+	rebuildBranch := flags.String("rebuild-branch", "", "Branch for -rebuild (default: branch_name with the -rebuild suffix)")
+	rebuildRepo := flags.String("rebuild-repo", "", "New repository directory for -rebuild instead of a branch of the project repository")
+	flags.Parse(args)
+
+	if *projectName == "" {
+		println("Project name is required: storage_to_git run -project NAME")
+		return 2
+	}
+
+	configPath, err := resolveConfigPath(*configFile)
+	if err != nil {
+		println("Failed to get executable path: " + err.Error())
+		return 1
+	}
+	config, err := configfile.Load(configPath)
+	if err != nil {
+		println("Invalid config: " + err.Error())
+		return 1
+	}
+
+	var project *models.Project
+	for i := range config.Projects {
+		if config.Projects[i].Name == *projectName {
+			project = &config.Projects[i]
+			break
+		}
+	}
+	if project == nil {
+		println("Project not found in config: " + *projectName)
+		return 1
+	}
+
+	logLevel := slog.LevelInfo
+	if config.LogLevel == "debug" {
+		logLevel = slog.LevelDebug
+	}
+	logging.AddSecrets(config.Secrets()...)
+	// In dry-run mode stdout is reserved for the plan.
+	logOutput := os.Stdout
+	if *dryRun {
+		logOutput = os.Stderr
+	}
+	slog.SetDefault(slog.New(logging.NewHandler(slog.NewTextHandler(logOutput, &slog.HandlerOptions{
+		Level: logLevel,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				a.Value = slog.StringValue(a.Value.Time().Format(time.TimeOnly))
+			}
+			return a
+		},
+	}))))
+	logConfigWarnings(config)
+
+	// The first signal stops after the current version, the second one kills running processes.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		slog.Info("Stopping after the current version, send the signal again to abort")
+		close(stop)
+		<-signals
+		cancel()
+	}()
+	ctx = models.WithLogger(ctx, slog.Default().With("project", project.Name))
+	ctx = models.WithStop(ctx, stop)
+
+	if *dryRun {
+		if err := planProject(ctx, config, project, os.Stdout); err != nil {
+			slog.Error("Dry run failed", "project", project.Name, "error", err)
+			return 1
+		}
+		return 0
+	}
+
+	if *rebuild {
+		target := runner.RebuildTarget{Branch: *rebuildBranch, RepositoryPath: *rebuildRepo}
+		match, err := rebuildProject(ctx, config, project, target, os.Stdout)
+		switch {
+		case err != nil:
+			slog.Error("Rebuild failed", "project", project.Name, "error", err)
+			return 1
+		case models.StopRequested(ctx):
+			slog.Warn("Rebuild interrupted", "project", project.Name)
+			return 1
+		case !match:
+			return 1
+		}
+		return 0
+	}
+
+	start := time.Now()
+	err = processProject(ctx, config, project)
+	switch {
+	case err != nil:
+		slog.Error("Run failed", "project", project.Name, "duration", time.Since(start).Round(time.Second).String(), "error", err)
+		return 1
+	case models.StopRequested(ctx):
+		slog.Warn("Run interrupted", "project", project.Name, "duration", time.Since(start).Round(time.Second).String())
+		return 1
+	}
+	slog.Info("Run finished", "project", project.Name, "duration", time.Since(start).Round(time.Second).String())
+	return 0
+}
+
+// rebuildProject converts all versions of the project again and prints how the trees compare
+// with the project branch. It reports whether every version matches.
+func rebuildProject(ctx context.Context, config *models.Config, project *models.Project, target runner.RebuildTarget, out io.Writer) (bool, error) {
+	ws := workspace.New(project)
+	lock, err := ws.Lock()
+	if err != nil {
+		return false, err
+	}
+	defer lock.Unlock()
+
+	if err := ws.Migrate(models.FromContext(ctx)); err != nil {
+		return false, err
+	}
+	users, err := storage.LoadUserMappings(ws.UsersFile())
+	if err != nil {
+		return false, fmt.Errorf("failed to load user mappings: %w", err)
+	}
+
+	executor, err := runner.NewExecutor(config, project)
+	if err != nil {
+		return false, err
+	}
+
+	comparisons, err := runner.Rebuild(ctx, config, project, users, executor, target)
+	if err != nil {
+		return false, err
+	}
+
+	matched := 0
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tSOURCE\tOLD TREE\tNEW TREE\tRESULT")
+	for _, c := range comparisons {
+		result := "differs"
+		switch {
+		case c.Match():
+			result = "match"
+			matched++
+		case c.OldTree == "":
+			result = "missing in project branch"
+		case c.NewTree == "":
+			result = "missing in rebuild"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", c.Version, c.Source, shortHash(c.OldTree), shortHash(c.NewTree), result)
+	}
+	if err := w.Flush(); err != nil {
+		return false, err
+	}
+	fmt.Fprintf(out, "\n%d of %d version(s) match for project %s\n", matched, len(comparisons), project.Name)
+	return matched == len(comparisons), nil
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	if hash == "" {
+		return "-"
+	}
+	return hash
+}
+
+// planProject prints the commits the next run of the project would make.
+func planProject(ctx context.Context, config *models.Config, project *models.Project, out io.Writer) error {
+	ws := workspace.New(project)
+	// The report uses the service infobase and overwrites the report files of the project.
+	// A dry run does not create the data directory to lock it: without it no run is in progress.
+	if _, err := os.Stat(ws.Dir); err == nil {
+		lock, err := ws.Lock()
+		if err != nil {
+			return err
+		}
+		defer lock.Unlock()
+	}
+
+	users, err := storage.LoadUserMappings(ws.UsersFileToRead())
+	if err != nil {
+		return fmt.Errorf("failed to load user mappings: %w", err)
+	}
+
+	executor, err := runner.NewExecutor(config, project)
+	if err != nil {
+		return err
+	}
+
+	versions, err := runner.Plan(ctx, project, users, executor)
+	if err != nil {
+		return err
+	}
+
+	messages, err := runner.NewCommitMessages(project)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tSOURCE\tAUTHOR\tDATE\tTAG\tMESSAGE")
+	for _, version := range versions {
+		source := "cf"
+		if version.Extension.ExtensionName != "" {
+			source = version.Extension.ExtensionName
+		}
+		subject, _, err := messages.Render(version)
+		if err != nil {
+			return err
+		}
+		message, _, _ := strings.Cut(subject, "\n")
+		fmt.Fprintf(w, "%s\t%s\t%s <%s>\t%s\t%s\t%s\n",
+			version.Version,
+			source,
+			version.User.GitUser,
+			version.User.GitEmail,
+			version.CreatedAt().Format(time.DateTime),
+			runner.TagName(version),
+			message,
+		)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "\n%d version(s) planned for project %s\n", len(versions), project.Name)
+	return nil
+}
+
+// setKeystoreSecret reads a secret from stdin and stores it in the keystore configured in config.
+func setKeystoreSecret(config *models.Config, configPath, name string) error {
+	resolver := secret.NewResolver(config, filepath.Dir(configPath))
+	masterKey, err := resolver.MasterKey()
+	if err != nil {
+		return err
+	}
+
+	secrets := make(map[string]string)
+	if _, err := os.Stat(resolver.KeystorePath()); err == nil {
+		if secrets, err = resolver.Keystore(); err != nil {
+			return err
+		}
+	}
+
+	value, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read secret from stdin: %w", err)
+	}
+	secrets[name] = strings.TrimRight(string(value), "\r\n")
+
+	return secret.SaveKeystore(resolver.KeystorePath(), masterKey, secrets)
+}
+
+// shutdown asks all projects to stop at the next safe point between versions and waits for
+// in-flight runs. If the timeout expires or a second signal arrives, runs are cancelled,
+// which kills running 1C processes. It returns the process exit code.
+func shutdown(signals <-chan os.Signal, timeout time.Duration) int {
+	projectMutex.Lock()
+	shuttingDown = true
+	for name, stop := range projectStopFuncs {
+		slog.Info("Stopping project", "project", name)
+		stop()
+	}
+	projectMutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		projectRuns.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		slog.Info("All projects stopped")
+		return 0
+	case <-time.After(timeout):
+		slog.Error("Shutdown timeout expired, cancelling running projects")
+	case sig := <-signals:
+		slog.Error("Received second signal, cancelling running projects", "signal", sig.String())
+	}
+
+	projectMutex.Lock()
+	for _, cancel := range projectCancelFuncs {
+		cancel()
+	}
+	projectMutex.Unlock()
+
+	select {
+	case <-done:
+		slog.Warn("Running projects cancelled")
+	case <-time.After(10 * time.Second):
+		slog.Error("Running projects did not finish after cancellation")
+	}
+	return 1
+}
+
+// beginRun registers an in-flight project run. It returns false once shutdown has started.
+func beginRun() bool {
+	projectMutex.Lock()
+	defer projectMutex.Unlock()
+
+	if shuttingDown {
+		return false
+	}
+	projectRuns.Add(1)
+	return true
 }
 
 func updateProjects(config *models.Config) {
 	projectMutex.Lock()
 	defer projectMutex.Unlock()
 
+	if shuttingDown {
+		return
+	}
+
 	activeProjects := make(map[string]bool)
 
 	for i := range config.Projects {
@@ -188,7 +587,11 @@ func updateProjects(config *models.Config) {
 				slog.Info("Stopping disabled project", "project", project.Name)
 				cancel()
 				delete(projectCancelFuncs, project.Name)
+				delete(projectStopFuncs, project.Name)
+				delete(projectSettings, project.Name)
+				delete(projectStates, project.Name)
 				delete(projectExecutionLocks, project.Name)
+				metrics.DeleteProject(project.Name)
 			}
 			continue
 		}
@@ -197,11 +600,11 @@ func updateProjects(config *models.Config) {
 
 		if _, exists := projectCancelFuncs[project.Name]; !exists {
 			projectExecutionLocks[project.Name] = &sync.Mutex{}
-			ctx, cancel := context.WithCancel(context.Background())
-			projectLogger := slog.Default().With("project", project.Name)
-			ctx = models.WithLogger(ctx, projectLogger)
-			projectCancelFuncs[project.Name] = cancel
-			go runProject(ctx, config, project)
+			startProject(config, project, nil)
+		} else if changed := models.Diff(projectSettings[project.Name], effectiveProject(config, project)); len(changed) > 0 {
+			slog.Info("Project settings changed, restarting project at next safe point", "project", project.Name, "fields", changed)
+			projectStopFuncs[project.Name]()
+			startProject(config, project, projectCancelFuncs[project.Name])
 		}
 	}
 
@@ -210,11 +613,67 @@ func updateProjects(config *models.Config) {
 			slog.Info("Stopping removed project", "project", name)
 			cancel()
 			delete(projectCancelFuncs, name)
+			delete(projectStopFuncs, name)
+			delete(projectSettings, name)
+			delete(projectStates, name)
 			delete(projectExecutionLocks, name)
+			metrics.DeleteProject(name)
 		}
 	}
 }
 
+// startProject launches the project loop. When previousCancel is set, the project replaces a
+// running instance that has already been asked to stop: the new loop starts only after the
+// previous run released the execution lock, so a version is never interrupted.
+// The caller must hold projectMutex.
+func startProject(config *models.Config, project *models.Project, previousCancel context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stopCtx, stop := context.WithCancel(ctx)
+	projectLogger := slog.Default().With("project", project.Name)
+	ctx = models.WithLogger(ctx, projectLogger)
+	ctx = models.WithStop(ctx, stopCtx.Done())
+	projectStopFuncs[project.Name] = stop
+	projectSettings[project.Name] = effectiveProject(config, project)
+
+	state, ok := projectStates[project.Name]
+	if !ok {
+		state = &projectState{pushes: runner.NewPushState()}
+		projectStates[project.Name] = state
+	}
+	ctx = runner.WithPushState(ctx, state.pushes)
+	state.ctx = ctx
+	state.config = config
+	state.project = project
+	state.nextRun = time.Time{}
+
+	if previousCancel == nil {
+		projectCancelFuncs[project.Name] = cancel
+		go runProject(ctx, config, project)
+		return
+	}
+
+	projectCancelFuncs[project.Name] = func() {
+		cancel()
+		previousCancel()
+	}
+	lock := projectExecutionLocks[project.Name]
+	go func() {
+		lock.Lock()
+		previousCancel()
+		lock.Unlock()
+		runProject(ctx, config, project)
+	}()
+}
+
+// effectiveProject returns the project settings with global defaults applied, used to detect changes on reload.
+func effectiveProject(config *models.Config, project *models.Project) models.Project {
+	settings := *project
+	if settings.Catalog1cv8 == "" {
+		settings.Catalog1cv8 = config.Catalog1cv8
+	}
+	return settings
+}
+
 func runProject(ctx context.Context, config *models.Config, project *models.Project) {
 	logger := models.FromContext(ctx)
 
@@ -229,62 +688,285 @@ func runProject(ctx context.Context, config *models.Config, project *models.Proj
 
 	logger.Info("Starting project")
 
-	// Initial run
-	lock.Lock()
-	processProject(ctx, config, project)
-	lock.Unlock()
-
 	if !project.ScheduleEnabled {
+		lock.Lock()
+		executeRun(ctx, config, project)
+		lock.Unlock()
 		logger.Info("Project is not scheduled for repeated runs.")
 		return
 	}
 
-	scheduleDuration, err := time.ParseDuration(project.Schedule)
+	sched, err := schedule.New(project)
 	if err != nil {
-		logger.Error("Invalid schedule duration for project", "schedule", project.Schedule, "error", err)
+		logger.Error("Invalid schedule for project", "schedule", project.Schedule, "error", err)
 		return
 	}
 
-	ticker := time.NewTicker(scheduleDuration)
-	defer ticker.Stop()
+	// Initial run
+	if sched.RunsAtStart() && sched.Allowed(time.Now()) && !isPaused(project.Name) {
+		lock.Lock()
+		executeRun(ctx, config, project)
+		lock.Unlock()
+	}
 
 	for {
+		next := sched.Next(time.Now())
+		if next.IsZero() {
+			logger.Warn("No allowed run time found for schedule, stopping scheduling", "schedule", project.Schedule)
+			return
+		}
+		setNextRun(project.Name, next)
+		logger.Info("Next run planned", "next_run", next)
+
+		timer := time.NewTimer(time.Until(next))
 		select {
-		case <-ticker.C:
-			if lock.TryLock() {
-				logger.Info("Ticker fired, starting scheduled run.")
+		case <-timer.C:
+			if isPaused(project.Name) {
+				logger.Info("Skipping scheduled run: project is paused.")
+			} else if lock.TryLock() {
+				logger.Info("Timer fired, starting scheduled run.")
 				go func() {
 					defer lock.Unlock()
-					processProject(ctx, config, project)
+					executeRun(ctx, config, project)
 				}()
 			} else {
 				logger.Info("Skipping scheduled run: project is still running.")
 			}
+		case <-models.StopChan(ctx):
+			timer.Stop()
+			logger.Info("Stopping project")
+			return
 		case <-ctx.Done():
+			timer.Stop()
 			logger.Info("Stopping project")
 			return
 		}
 	}
 }
 
-func processProject(ctx context.Context, config *models.Config, project *models.Project) {
+// setNextRun records the next planned run of the project.
+func setNextRun(name string, next time.Time) {
+	projectMutex.Lock()
+	defer projectMutex.Unlock()
+
+	if state, ok := projectStates[name]; ok {
+		state.nextRun = next
+	}
+}
+
+func isPaused(name string) bool {
+	projectMutex.Lock()
+	defer projectMutex.Unlock()
+
+	state, ok := projectStates[name]
+	return ok && state.paused
+}
+
+// executeRun processes the project once and records the outcome in its state.
+// The caller must hold the project execution lock.
+func executeRun(ctx context.Context, config *models.Config, project *models.Project) {
+	if !beginRun() {
+		return
+	}
+	defer projectRuns.Done()
+
+	projectMutex.Lock()
+	state := projectStates[project.Name]
+	if state != nil {
+		state.running = true
+		state.lastRunStarted = time.Now()
+	}
+	projectMutex.Unlock()
+
+	metrics.RunsStarted.Inc(project.Name)
+	err := processProject(ctx, config, project)
+	if err != nil {
+		metrics.RunsFailed.Inc(project.Name)
+	} else {
+		metrics.RunsSucceeded.Inc(project.Name)
+		metrics.LastSuccess.Set(float64(time.Now().Unix()), project.Name)
+	}
+
+	projectMutex.Lock()
+	if state != nil {
+		state.running = false
+		state.lastRunFinished = time.Now()
+		state.lastError = ""
+		if err != nil {
+			state.lastError = err.Error()
+		}
+	}
+	projectMutex.Unlock()
+}
+
+func processProject(ctx context.Context, config *models.Config, project *models.Project) error {
 	logger := models.FromContext(ctx)
+	if models.StopRequested(ctx) {
+		return nil
+	}
 	logger.Info("Processing project")
 
-	versions, err := storage.LoadOrInitVersions(filepath.Join(filepath.Dir(project.ProjectDataPath), project.VersionsFilePath), *project)
+	ws := workspace.New(project)
+	lock, err := ws.Lock()
 	if err != nil {
-		logger.Error("Error loading or initializing versions for project", "error", err)
-		return
+		logger.Error("Project is not processed", "error", err)
+		return err
 	}
+	defer lock.Unlock()
 
-	logger.Info("Successfully loaded versions for project", "versions", versions)
+	if err := ws.Migrate(logger); err != nil {
+		logger.Error("Error preparing project data directory", "error", err)
+		return err
+	}
 
-	users, err := storage.LoadUserMappings(filepath.Join(filepath.Dir(project.ProjectDataPath), project.UsersFilePath))
+	versions, err := storage.LoadOrInitVersions(logger, ws.VersionsFile(), *project)
+	if errors.Is(err, storage.ErrVersionsCorrupt) {
+		// The runner reconstructs the versions from the git history.
+		logger.Warn("Versions file and its backup are unreadable", "error", err)
+	} else if err != nil {
+		logger.Error("Error loading or initializing versions for project", "error", err)
+		return err
+	} else {
+		logger.Info("Successfully loaded versions for project", "versions", versions)
+	}
+
+	users, err := storage.LoadUserMappings(ws.UsersFile())
 	if err != nil {
 		logger.Error("Error loading user mappings for project", "error", err)
-		return
+		return err
 	}
 	logger.Debug("Successfully loaded users for project", "users", users)
 
-	runner.Run(ctx, config, project, users)
-}
\ No newline at end of file
+	executor, err := runner.NewExecutor(config, project)
+	if err != nil {
+		logger.Error("Error creating 1C executor for project", "error", err)
+		return err
+	}
+
+	err = runner.Run(ctx, config, project, users, executor)
+	if err != nil {
+		if errors.Is(err, runner.ErrStopped) {
+			logger.Info("Project run stopped at a safe point", "reason", err)
+			return nil
+		} else if errors.Is(err, runner.ErrTimeout) {
+			logger.Error("Project run timed out", "error", err)
+		} else {
+			logger.Error("Project run failed", "error", err)
+		}
+	}
+	return err
+}
+
+// projectController exposes the running projects to the control API.
+type projectController struct{}
+
+func (projectController) Projects() []api.ProjectStatus {
+	projectMutex.Lock()
+	names := make([]string, 0, len(projectStates))
+	for name := range projectStates {
+		names = append(names, name)
+	}
+	projectMutex.Unlock()
+	sort.Strings(names)
+
+	statuses := make([]api.ProjectStatus, 0, len(names))
+	for _, name := range names {
+		if status, err := (projectController{}).Project(name); err == nil {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses
+}
+
+func (projectController) Project(name string) (api.ProjectStatus, error) {
+	projectMutex.Lock()
+	state, ok := projectStates[name]
+	if !ok {
+		projectMutex.Unlock()
+		return api.ProjectStatus{}, api.ErrNotFound
+	}
+	status := api.ProjectStatus{
+		Name:            name,
+		State:           api.StateIdle,
+		Paused:          state.paused,
+		LastError:       state.lastError,
+		LastRunStarted:  optionalTime(state.lastRunStarted),
+		LastRunFinished: optionalTime(state.lastRunFinished),
+		NextRun:         optionalTime(state.nextRun),
+	}
+	switch {
+	case state.running:
+		status.State = api.StateRunning
+	case state.paused:
+		status.State = api.StatePaused
+	}
+	project, pushes := state.project, state.pushes
+	projectMutex.Unlock()
+
+	for _, remote := range pushes.Remotes() {
+		status.UnpushedCommits += remote.Unpushed
+		status.Remotes = append(status.Remotes, api.RemoteStatus{
+			Name:            remote.Remote,
+			UnpushedCommits: remote.Unpushed,
+			PushFailures:    remote.Failures,
+			LastPushError:   remote.LastError,
+			LastPushFailure: optionalTime(remote.LastFailure),
+			NextPushAttempt: optionalTime(remote.NextAttempt),
+		})
+	}
+
+	versions, err := storage.LoadVersions(slog.Default().With("project", name), workspace.New(project).VersionsFile())
+	if err != nil {
+		slog.Warn("Failed to read versions for status", "project", name, "error", err)
+	}
+	status.Versions = versions
+	return status, nil
+}
+
+func (projectController) Trigger(name string) error {
+	projectMutex.Lock()
+	state, ok := projectStates[name]
+	lock := projectExecutionLocks[name]
+	if !ok || lock == nil {
+		projectMutex.Unlock()
+		return api.ErrNotFound
+	}
+	ctx, config, project := state.ctx, state.config, state.project
+	projectMutex.Unlock()
+
+	if !lock.TryLock() {
+		return api.ErrBusy
+	}
+	go func() {
+		defer lock.Unlock()
+		executeRun(ctx, config, project)
+	}()
+	return nil
+}
+
+func (projectController) Pause(name string) error {
+	return setPaused(name, true)
+}
+
+func (projectController) Resume(name string) error {
+	return setPaused(name, false)
+}
+
+func setPaused(name string, paused bool) error {
+	projectMutex.Lock()
+	defer projectMutex.Unlock()
+
+	state, ok := projectStates[name]
+	if !ok {
+		return api.ErrNotFound
+	}
+	state.paused = paused
+	return nil
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,262 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	RunsStarted = NewCounter("storage_to_git_runs_started_total",
+		"Number of project runs started.", "project")
+	RunsSucceeded = NewCounter("storage_to_git_runs_succeeded_total",
+		"Number of project runs finished without errors.", "project")
+	RunsFailed = NewCounter("storage_to_git_runs_failed_total",
+		"Number of project runs finished with an error.", "project")
+	VersionsCommitted = NewCounter("storage_to_git_versions_committed_total",
+		"Number of storage versions committed to git.", "project", "key")
+	OperationDuration = NewHistogram("storage_to_git_operation_duration_seconds",
+		"Duration of 1C and git operations.",
+		// Dumps and pushes of large configurations take hours.
+		[]float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600, 7200, 14400, 28800, 57600}, "project", "operation")
+	LastSuccess = NewGauge("storage_to_git_last_success_timestamp_seconds",
+		"Unix time of the last successful project run.", "project")
+	VersionLag = NewGauge("storage_to_git_version_lag",
+		"Difference between the newest storage version in the report and the last committed version.", "project", "key")
+	UnpushedCommits = NewGauge("storage_to_git_unpushed_commits",
+		"Commits of the project branch that did not reach the remote.", "project", "remote")
+)
+
+var registry = []collector{RunsStarted, RunsSucceeded, RunsFailed, VersionsCommitted, OperationDuration, LastSuccess, VersionLag, UnpushedCommits}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+type collector interface {
+	write(w io.Writer)
+	deleteProject(project string)
+}
+
+type desc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+func (d *desc) header(w io.Writer, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, d.help, d.name, kind)
+}
+
+// key joins label values into a series key. A wrong number of values is a programming error:
+// it is logged and the sample is dropped rather than stopping the service.
+func (d *desc) key(values []string) (string, bool) {
+	if len(values) != len(d.labels) {
+		slog.Error("Metric sample dropped", "metric", d.name, "labels", len(d.labels), "values", len(values))
+		return "", false
+	}
+	return strings.Join(values, "\xff"), true
+}
+
+// ofProject reports whether the series key belongs to the project, which is the first label.
+func (d *desc) ofProject(key, project string) bool {
+	if len(d.labels) == 0 || d.labels[0] != "project" {
+		return false
+	}
+	value, _, _ := strings.Cut(key, "\xff")
+	return value == project
+}
+
+// labelString renders the label set for a series key, with optional extra label pairs.
+func (d *desc) labelString(key string, extra ...string) string {
+	var pairs []string
+	if len(d.labels) > 0 {
+		for i, value := range strings.Split(key, "\xff") {
+			pairs = append(pairs, fmt.Sprintf(`%s="%s"`, d.labels[i], labelEscaper.Replace(value)))
+		}
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extra[i], labelEscaper.Replace(extra[i+1])))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// values is a set of series guarded by a mutex, shared by counters and gauges.
+type values struct {
+	desc
+	kind   string
+	mu     sync.Mutex
+	series map[string]float64
+}
+
+func (v *values) write(w io.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.header(w, v.kind)
+	for _, key := range sortedKeys(v.series) {
+		fmt.Fprintf(w, "%s%s %s\n", v.name, v.labelString(key), formatFloat(v.series[key]))
+	}
+}
+
+func (v *values) deleteProject(project string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for key := range v.series {
+		if v.ofProject(key, project) {
+			delete(v.series, key)
+		}
+	}
+}
+
+type Counter struct {
+	values
+}
+
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{values{desc: desc{name, help, labels}, kind: "counter", series: make(map[string]float64)}}
+}
+
+func (c *Counter) Inc(labels ...string) {
+	c.Add(1, labels...)
+}
+
+func (c *Counter) Add(delta float64, labels ...string) {
+	key, ok := c.key(labels)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.series[key] += delta
+}
+
+type Gauge struct {
+	values
+}
+
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{values{desc: desc{name, help, labels}, kind: "gauge", series: make(map[string]float64)}}
+}
+
+func (g *Gauge) Set(value float64, labels ...string) {
+	key, ok := g.key(labels)
+	if !ok {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.series[key] = value
+}
+
+type Histogram struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return &Histogram{desc: desc{name, help, labels}, buckets: buckets, series: make(map[string]*histogramSeries)}
+}
+
+func (h *Histogram) Observe(value float64, labels ...string) {
+	key, ok := h.key(labels)
+	if !ok {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += value
+}
+
+// Since observes the time elapsed from start in seconds.
+func (h *Histogram) Since(start time.Time, labels ...string) {
+	h.Observe(time.Since(start).Seconds(), labels...)
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.header(w, "histogram")
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelString(key, "le", formatFloat(bound)), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelString(key, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelString(key), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelString(key), s.count)
+	}
+}
+
+func (h *Histogram) deleteProject(project string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for key := range h.series {
+		if h.ofProject(key, project) {
+			delete(h.series, key)
+		}
+	}
+}
+
+// DeleteProject removes all series of a project, so a removed or renamed project
+// is not reported with its last values until the service restarts.
+func DeleteProject(project string) {
+	for _, c := range registry {
+		c.deleteProject(project)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Handler serves all metrics in the Prometheus text exposition format.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		for _, c := range registry {
+			c.write(w)
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	counter := NewCounter("test_runs_total", "Runs.", "project")
+	counter.Inc("erp")
+	counter.Add(2, `a"b`)
+	histogram := NewHistogram("test_duration_seconds", "Duration.", []float64{1, 10}, "project")
+	histogram.Observe(0.5, "erp")
+	histogram.Observe(5, "erp")
+
+	var b strings.Builder
+	counter.write(&b)
+	histogram.write(&b)
+	want := `# HELP test_runs_total Runs.
+# TYPE test_runs_total counter
+test_runs_total{project="a\"b"} 2
+test_runs_total{project="erp"} 1
+# HELP test_duration_seconds Duration.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{project="erp",le="1"} 1
+test_duration_seconds_bucket{project="erp",le="10"} 2
+test_duration_seconds_bucket{project="erp",le="+Inf"} 2
+test_duration_seconds_sum{project="erp"} 5.5
+test_duration_seconds_count{project="erp"} 2
+`
+	if b.String() != want {
+		t.Errorf("output:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestLabelMismatchDropsSample(t *testing.T) {
+	gauge := NewGauge("test_lag", "Lag.", "project", "key")
+	gauge.Set(1, "erp")
+	gauge.Set(2, "erp", "cf", "extra")
+	if len(gauge.series) != 0 {
+		t.Errorf("series = %v, want samples with a wrong label count dropped", gauge.series)
+	}
+}
+
+func TestDeleteProject(t *testing.T) {
+	RunsStarted.Inc("deleted-project")
+	VersionLag.Set(3, "deleted-project", "cf")
+	OperationDuration.Observe(1, "deleted-project", "dump")
+	RunsStarted.Inc("kept-project")
+
+	DeleteProject("deleted-project")
+
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+	if strings.Contains(body, "deleted-project") {
+		t.Errorf("series of the deleted project are still exported:\n%s", body)
+	}
+	if !strings.Contains(body, `storage_to_git_runs_started_total{project="kept-project"} 1`) {
+		t.Errorf("series of other projects are gone:\n%s", body)
+	}
+}
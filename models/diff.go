@@ -0,0 +1,56 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Diff returns the JSON paths of the fields that differ between two values of the same struct type,
+// e.g. "branch_name" or "extensions[0].storage_user".
+func Diff(old, new any) []string {
+	var fields []string
+	diffValues(reflect.ValueOf(old), reflect.ValueOf(new), "", &fields)
+	return fields
+}
+
+func diffValues(old, new reflect.Value, path string, fields *[]string) {
+	switch old.Kind() {
+	case reflect.Pointer:
+		if old.IsNil() || new.IsNil() {
+			if old.IsNil() != new.IsNil() {
+				*fields = append(*fields, path)
+			}
+			return
+		}
+		diffValues(old.Elem(), new.Elem(), path, fields)
+	case reflect.Struct:
+		t := old.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "" || name == "-" {
+				name = field.Name
+			}
+			if path != "" {
+				name = path + "." + name
+			}
+			diffValues(old.Field(i), new.Field(i), name, fields)
+		}
+	case reflect.Slice:
+		if old.Len() != new.Len() {
+			*fields = append(*fields, path)
+			return
+		}
+		for i := 0; i < old.Len(); i++ {
+			diffValues(old.Index(i), new.Index(i), fmt.Sprintf("%s[%d]", path, i), fields)
+		}
+	default:
+		if !reflect.DeepEqual(old.Interface(), new.Interface()) {
+			*fields = append(*fields, path)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	base := func() Project {
+		return Project{
+			Name:       "erp",
+			BranchName: "master",
+			InfoBase:   InfoBase{InfoBaseServer: "srv1c", InfoBaseRef: "erp", InfoBasePassword: "ib-secret"},
+			GitRemotes: []Remote{
+				{Name: "origin", URL: "git@example.com:erp.git", PushBranches: true, Enabled: true},
+				{Name: "backup", URL: "/mnt/backup/erp.git", PushTags: true, Enabled: true},
+			},
+			Storage: &Storage{StoragePath: "tcp://srv/erp", StorageUser: "reader", StoragePassword: "storage-secret"},
+			Extensions: []Extension{
+				{ExtensionName: "Sales", StoragePath: "tcp://srv/sales", StoragePassword: "sales-secret"},
+				{ExtensionName: "HR", StoragePath: "tcp://srv/hr"},
+			},
+		}
+	}
+	tests := []struct {
+		name   string
+		change func(p *Project)
+		want   []string
+	}{
+		{"nothing", func(p *Project) {}, nil},
+		{"top-level field", func(p *Project) { p.BranchName = "main" }, []string{"branch_name"}},
+		{"nested struct", func(p *Project) { p.InfoBase.InfoBaseRef = "erp2" }, []string{"infobase.infobase_ref"}},
+		{"pointer set", func(p *Project) { p.ClusterAdmin = &ClusterAdmin{Enabled: true} }, []string{"cluster_admin"}},
+		{"pointer cleared", func(p *Project) { p.Storage = nil }, []string{"storage"}},
+		{"pointer target", func(p *Project) { p.Storage.TagPrefix = "cf/" }, []string{"storage.tag_prefix"}},
+		{"extension field", func(p *Project) { p.Extensions[1].StorageUser = "hr" }, []string{"extensions[1].storage_user"}},
+		{
+			name: "several extension fields",
+			change: func(p *Project) {
+				p.Extensions[0].StoragePath = "tcp://srv2/sales"
+				p.Extensions[1].GitRepositoryPath = "src/cfe"
+			},
+			want: []string{"extensions[0].storage_path", "extensions[1].git_repository_path"},
+		},
+		{"extension added", func(p *Project) { p.Extensions = append(p.Extensions, Extension{ExtensionName: "CRM"}) }, []string{"extensions"}},
+		{"remote URL", func(p *Project) { p.GitRemotes[1].URL = "/mnt/backup2/erp.git" }, []string{"git_remotes[1].url"}},
+		{"remote disabled", func(p *Project) { p.GitRemotes[0].Enabled = false }, []string{"git_remotes[0].enabled"}},
+		{"remote removed", func(p *Project) { p.GitRemotes = p.GitRemotes[:1] }, []string{"git_remotes"}},
+		{"schedule windows", func(p *Project) { p.ScheduleWindows = []string{"20:00-06:00"} }, []string{"schedule_windows"}},
+		{
+			name: "secrets",
+			change: func(p *Project) {
+				p.InfoBase.InfoBasePassword = "new-ib-secret"
+				p.Storage.StoragePassword = "new-storage-secret"
+				p.Extensions[0].StoragePassword = "new-sales-secret"
+			},
+			want: []string{"infobase.infobase_password", "storage.storage_password", "extensions[0].storage_password"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old, changed := base(), base()
+			tt.change(&changed)
+			got := Diff(old, changed)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff = %q, want %q", got, tt.want)
+			}
+			// Secret fields are reported by name only, so the result is safe to log.
+			for _, field := range got {
+				if strings.Contains(field, "secret") {
+					t.Errorf("Diff exposes a value: %q", field)
+				}
+			}
+		})
+	}
+}
@@ -18,50 +18,177 @@ func FromContext(ctx context.Context) *slog.Logger {
 	return slog.Default()
 }
 
+type stopKey struct{}
+
+// WithStop attaches a channel that is closed when the project must stop at the next safe point.
+// Unlike cancelling the context, a stop request lets the current version finish.
+func WithStop(ctx context.Context, stop <-chan struct{}) context.Context {
+	return context.WithValue(ctx, stopKey{}, stop)
+}
+
+// StopChan returns the channel attached by WithStop. A nil channel is never closed.
+func StopChan(ctx context.Context) <-chan struct{} {
+	stop, _ := ctx.Value(stopKey{}).(<-chan struct{})
+	return stop
+}
+
+func StopRequested(ctx context.Context) bool {
+	select {
+	case <-StopChan(ctx):
+		return true
+	default:
+		return false
+	}
+}
+
 type Config struct {
-	LogLevel    string    `json:"log_level"`
-	AppLogDir   string    `json:"app_log_dir"`
-	Catalog1cv8 string    `json:"catalog_1cv8"`
-	Projects    []Project `json:"projects"`
+	LogLevel        string `json:"log_level"`
+	AppLogDir       string `json:"app_log_dir"`
+	Catalog1cv8     string `json:"catalog_1cv8"`
+	ShutdownTimeout string `json:"shutdown_timeout,omitempty"`
+	HTTPAddress     string `json:"http_address,omitempty"`
+	// KeystorePath is the encrypted keystore used by "keystore:NAME" password references.
+	KeystorePath string `json:"keystore_path,omitempty"`
+	// KeystoreMasterKey is a reference to the keystore master key ("env:NAME" or "file:/path").
+	KeystoreMasterKey string    `json:"keystore_master_key,omitempty"`
+	Projects          []Project `json:"projects"`
 }
 
+const (
+	BackendDesigner = "designer"
+	BackendIbcmd    = "ibcmd"
+)
+
 type Project struct {
-	Name                          string      `json:"project"`
-	Catalog1cv8                   string      `json:"catalog_1cv8,omitempty"`
-	Enabled                       bool        `json:"enabled"`
-	Schedule                      string      `json:"schedule"`
-	ScheduleEnabled               bool        `json:"schedule_enabled"`
-	ProjectDataPath               string      `json:"project_data_path"`
-	UsersFilePath                 string      `json:"users_file_path"`
-	VersionsFilePath              string      `json:"versions_file_path"`
-	V8LogFilePath                 string      `json:"v8_log_file_path"`
-	GitRepositoryPath             string      `json:"git_repository_path"`
-	GitRemoteUrl                  string      `json:"git_remote_url"`
-	BranchName                    string      `json:"branch_name"`
-	GitPushEnabled                bool        `json:"git_push_enabled"`
-	GitPushTimingAfterEachCommit  bool        `json:"git_push_timing_after_each_commit"`
-	InfoBase                      InfoBase    `json:"infobase"`
-	Storage                       *Storage    `json:"storage,omitempty"`
-	Extensions                    []Extension `json:"extensions,omitempty"`
+	Name                         string         `json:"project"`
+	Catalog1cv8                  string         `json:"catalog_1cv8,omitempty"`
+	Backend                      string         `json:"backend,omitempty"`
+	Enabled                      bool           `json:"enabled"`
+	Schedule                     string         `json:"schedule"`
+	ScheduleEnabled              bool           `json:"schedule_enabled"`
+	ScheduleWindows              []string       `json:"schedule_windows,omitempty"`
+	ScheduleBlackouts            []Blackout     `json:"schedule_blackouts,omitempty"`
+	ScheduleJitter               string         `json:"schedule_jitter,omitempty"`
+	ProjectDataPath              string         `json:"project_data_path"`
+	UsersFilePath                string         `json:"users_file_path"`
+	VersionsFilePath             string         `json:"versions_file_path"`
+	V8LogFilePath                string         `json:"v8_log_file_path"`
+	GitRepositoryPath            string         `json:"git_repository_path"`
+	GitRemoteUrl                 string         `json:"git_remote_url"`
+	GitRemotes                   []Remote       `json:"git_remotes,omitempty"`
+	GitBackend                   string         `json:"git_backend,omitempty"`
+	GitCommitter                 *GitCommitter  `json:"git_committer,omitempty"`
+	GitSigning                   *GitSigning    `json:"git_signing,omitempty"`
+	BranchName                   string         `json:"branch_name"`
+	GitPushEnabled               bool           `json:"git_push_enabled"`
+	GitPushTimingAfterEachCommit bool           `json:"git_push_timing_after_each_commit"`
+	InfoBase                     InfoBase       `json:"infobase"`
+	ClusterAdmin                 *ClusterAdmin  `json:"cluster_admin,omitempty"`
+	Timeouts                     *Timeouts      `json:"timeouts,omitempty"`
+	CommitMessage                *CommitMessage `json:"commit_message,omitempty"`
+	Storage                      *Storage       `json:"storage,omitempty"`
+	Extensions                   []Extension    `json:"extensions,omitempty"`
 }
 
 type InfoBase struct {
-	InfoBasePath     string `json:"infobase_path"`
+	InfoBasePath     string `json:"infobase_path,omitempty"`
+	InfoBaseFile     string `json:"infobase_file,omitempty"`
+	InfoBaseServer   string `json:"infobase_server,omitempty"`
+	InfoBaseRef      string `json:"infobase_ref,omitempty"`
 	InfoBaseUser     string `json:"infobase_user"`
 	InfoBasePassword string `json:"infobase_password"`
 }
 
+type ClusterAdmin struct {
+	Enabled           bool   `json:"enabled"`
+	RasAddress        string `json:"ras_address,omitempty"`
+	ClusterUser       string `json:"cluster_user,omitempty"`
+	ClusterPassword   string `json:"cluster_password,omitempty"`
+	TerminateSessions bool   `json:"terminate_sessions"`
+	LockSessions      bool   `json:"lock_sessions"`
+	LockMessage       string `json:"lock_message,omitempty"`
+	PermissionCode    string `json:"permission_code,omitempty"`
+}
+
+// Blackout is a period in local time ("2006-01-02 15:04") when scheduled runs must not start.
+type Blackout struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Timeouts limits the duration of 1C platform processes. Values use time.ParseDuration format.
+type Timeouts struct {
+	Default string `json:"default,omitempty"`
+	Report  string `json:"report,omitempty"`
+	Unbind  string `json:"unbind,omitempty"`
+	Update  string `json:"update,omitempty"`
+	Dump    string `json:"dump,omitempty"`
+	Rac     string `json:"rac,omitempty"`
+}
+
+// Remote is a git remote the project branch and tags are pushed to.
+type Remote struct {
+	Name         string `json:"name"`
+	URL          string `json:"url"`
+	PushBranches bool   `json:"push_branches"`
+	PushTags     bool   `json:"push_tags"`
+	Enabled      bool   `json:"enabled"`
+}
+
+// Remotes returns the remotes of the project. Without git_remotes, git_remote_url is the
+// "origin" remote receiving both the branch and the tags.
+func (p *Project) Remotes() []Remote {
+	if len(p.GitRemotes) > 0 {
+		return p.GitRemotes
+	}
+	if p.GitRemoteUrl == "" {
+		return nil
+	}
+	return []Remote{{Name: "origin", URL: p.GitRemoteUrl, PushBranches: true, PushTags: true, Enabled: true}}
+}
+
+// GitCommitter is the committer of converted versions. Without it the git config is used.
+type GitCommitter struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+	// DateFromVersion sets the committer date to the version time instead of the current time,
+	// so converting the same versions again yields the same commit hashes.
+	DateFromVersion bool `json:"date_from_version,omitempty"`
+}
+
+// GitSigning signs commits and annotated tags.
+type GitSigning struct {
+	// Format is "openpgp" or "ssh".
+	Format string `json:"format"`
+	// Key is the user.signingkey value for the exec git backend and the armored private key file
+	// for the go backend.
+	Key        string `json:"key"`
+	Passphrase string `json:"passphrase,omitempty"`
+}
+
+// CommitMessage holds text/template templates for commit messages of storage versions.
+// Templates are executed with the version (see runner.MessageData).
+type CommitMessage struct {
+	Subject         string `json:"subject,omitempty"`
+	Body            string `json:"body,omitempty"`
+	FallbackSubject string `json:"fallback_subject,omitempty"`
+}
+
 type Storage struct {
-	StoragePath     string `json:"storage_path"`
-	StorageUser     string `json:"storage_user"`
-	StoragePassword string `json:"storage_password"`
-	GitRepositoryPath   string `json:"git_repository_path"`
+	StoragePath       string `json:"storage_path"`
+	StorageUser       string `json:"storage_user"`
+	StoragePassword   string `json:"storage_password"`
+	GitRepositoryPath string `json:"git_repository_path"`
+	// TagPrefix is prepended to the names of tags created for storage labels, e.g. "cf/".
+	TagPrefix string `json:"tag_prefix,omitempty"`
 }
 
 type Extension struct {
-	ExtensionName            string `json:"extension_name"`
-	StoragePath     string `json:"storage_path"`
-	StorageUser     string `json:"storage_user"`
-	StoragePassword string `json:"storage_password"`
-	GitRepositoryPath        string `json:"git_repository_path"`
-}
\ No newline at end of file
+	ExtensionName     string `json:"extension_name"`
+	StoragePath       string `json:"storage_path"`
+	StorageUser       string `json:"storage_user"`
+	StoragePassword   string `json:"storage_password"`
+	GitRepositoryPath string `json:"git_repository_path"`
+	// TagPrefix is prepended to the names of tags created for storage labels, e.g. "ext/sales/".
+	TagPrefix string `json:"tag_prefix,omitempty"`
+}
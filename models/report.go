@@ -3,19 +3,36 @@ package models
 import "time"
 
 type ReportVersion struct {
-	Version           string
-	Label             string
-	ConfigVersion     string
-	User              UserMapping
-	CreationDate      time.Time
-	CreationTime      time.Time
-	Comment           string
-	AddedCount        int
-	ChangedCount      int
-	FileName          string
-	StoragePath       string
-	Storage Storage
-	Extension Extension
+	Version       string
+	Label         string
+	LabelComment  string
+	ConfigVersion string
+	// StorageUser is the user name from the report; User is the git author it maps to.
+	StorageUser  string
+	User         UserMapping
+	CreationDate time.Time
+	CreationTime time.Time
+	Comment      string
+	AddedCount   int
+	ChangedCount int
+	FileName     string
+	StoragePath  string
+	Storage      Storage
+	Extension    Extension
+}
+
+// CreatedAt returns the creation date and time of the version as a single value.
+func (v ReportVersion) CreatedAt() time.Time {
+	return time.Date(
+		v.CreationDate.Year(),
+		v.CreationDate.Month(),
+		v.CreationDate.Day(),
+		v.CreationTime.Hour(),
+		v.CreationTime.Minute(),
+		v.CreationTime.Second(),
+		0,
+		v.CreationDate.Location(),
+	)
 }
 
 type Report struct {
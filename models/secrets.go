@@ -0,0 +1,32 @@
+package models
+
+import "net/url"
+
+// Secrets returns the passwords and session permission codes of all projects in the config,
+// including credentials embedded in git remote URLs.
+func (c *Config) Secrets() []string {
+	var secrets []string
+	for _, project := range c.Projects {
+		secrets = append(secrets, project.InfoBase.InfoBasePassword)
+		if project.ClusterAdmin != nil {
+			secrets = append(secrets, project.ClusterAdmin.ClusterPassword, project.ClusterAdmin.PermissionCode)
+		}
+		if project.Storage != nil {
+			secrets = append(secrets, project.Storage.StoragePassword)
+		}
+		for _, ext := range project.Extensions {
+			secrets = append(secrets, ext.StoragePassword)
+		}
+		if project.GitSigning != nil {
+			secrets = append(secrets, project.GitSigning.Passphrase)
+		}
+		for _, remote := range project.Remotes() {
+			if u, err := url.Parse(remote.URL); err == nil && u.User != nil {
+				if password, ok := u.User.Password(); ok {
+					secrets = append(secrets, password)
+				}
+			}
+		}
+	}
+	return secrets
+}
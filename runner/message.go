@@ -0,0 +1,147 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"storage_to_git/models"
+)
+
+const (
+	// defaultSubject keeps the storage comment as the whole message, as earlier releases did.
+	defaultSubject         = "{{.Comment}}"
+	defaultFallbackSubject = "Storage version {{.Version}}"
+)
+
+// MessageData is the value commit message templates are executed with, e.g. {{.Version}},
+// {{.Label}} or {{.User.GitUser}}. It copies the fields of the version that describe the change,
+// so templates cannot reach the storage credentials of the project.
+type MessageData struct {
+	Version       string
+	Label         string
+	LabelComment  string
+	ConfigVersion string
+	// StorageUser is the user name from the report; User is the git author it maps to.
+	StorageUser  string
+	User         models.UserMapping
+	CreatedAt    time.Time
+	Comment      string
+	AddedCount   int
+	ChangedCount int
+	// StoragePath is the storage path from the report.
+	StoragePath string
+	Extension   MessageExtension
+	// Project is the project name.
+	Project string
+	// Source is "cf" for the main configuration or the extension name.
+	Source string
+}
+
+// MessageExtension is the extension of a version in MessageData, empty for the main configuration.
+type MessageExtension struct {
+	ExtensionName string
+}
+
+func newMessageData(project string, version models.ReportVersion) MessageData {
+	data := MessageData{
+		Version:       version.Version,
+		Label:         version.Label,
+		LabelComment:  version.LabelComment,
+		ConfigVersion: version.ConfigVersion,
+		StorageUser:   version.StorageUser,
+		User:          version.User,
+		CreatedAt:     version.CreatedAt(),
+		Comment:       version.Comment,
+		AddedCount:    version.AddedCount,
+		ChangedCount:  version.ChangedCount,
+		StoragePath:   version.StoragePath,
+		Extension:     MessageExtension{ExtensionName: version.Extension.ExtensionName},
+		Project:       project,
+		Source:        "cf",
+	}
+	if version.Extension.ExtensionName != "" {
+		data.Source = version.Extension.ExtensionName
+	}
+	return data
+}
+
+var messageFuncs = template.FuncMap{
+	"firstLine": func(s string) string {
+		line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+		return strings.TrimSpace(line)
+	},
+	"trim": strings.TrimSpace,
+}
+
+// CommitMessages renders commit messages of a project from its commit_message templates.
+type CommitMessages struct {
+	project  string
+	subject  *template.Template
+	body     *template.Template
+	fallback *template.Template
+}
+
+func NewCommitMessages(project *models.Project) (*CommitMessages, error) {
+	settings := models.CommitMessage{}
+	if project.CommitMessage != nil {
+		settings = *project.CommitMessage
+	}
+	if settings.Subject == "" {
+		settings.Subject = defaultSubject
+	}
+	if settings.FallbackSubject == "" {
+		settings.FallbackSubject = defaultFallbackSubject
+	}
+
+	m := &CommitMessages{project: project.Name}
+	var err error
+	if m.subject, err = parseMessageTemplate("subject", settings.Subject); err != nil {
+		return nil, err
+	}
+	if m.body, err = parseMessageTemplate("body", settings.Body); err != nil {
+		return nil, err
+	}
+	if m.fallback, err = parseMessageTemplate("fallback_subject", settings.FallbackSubject); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func parseMessageTemplate(name, text string) (*template.Template, error) {
+	t, err := template.New(name).Funcs(messageFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid commit message %s template: %w", name, err)
+	}
+	return t, nil
+}
+
+// Render returns the subject and body of the commit message of a version. The fallback subject
+// is used when the storage comment is empty or the subject template renders to nothing.
+func (m *CommitMessages) Render(version models.ReportVersion) (subject, body string, err error) {
+	data := newMessageData(m.project, version)
+
+	if strings.TrimSpace(version.Comment) != "" {
+		if subject, err = execute(m.subject, data); err != nil {
+			return "", "", err
+		}
+	}
+	if subject == "" {
+		if subject, err = execute(m.fallback, data); err != nil {
+			return "", "", err
+		}
+	}
+	if body, err = execute(m.body, data); err != nil {
+		return "", "", err
+	}
+	return subject, body, nil
+}
+
+func execute(t *template.Template, data MessageData) (string, error) {
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render commit message %s: %w", t.Name(), err)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
@@ -0,0 +1,61 @@
+package runner
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"storage_to_git/models"
+)
+
+func TestCommitMessagesRender(t *testing.T) {
+	version := models.ReportVersion{
+		Version:       "7",
+		Label:         "v2.0",
+		ConfigVersion: "2.0.1",
+		StorageUser:   "ivan",
+		User:          models.UserMapping{StorageUser: "ivan", GitUser: "Ivan Ivanov", GitEmail: "ivanov@example.com"},
+		CreationDate:  time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC),
+		CreationTime:  time.Date(1, 1, 1, 14, 30, 0, 0, time.UTC),
+		Comment:       "Fix posting\nsecond line",
+		AddedCount:    1,
+		ChangedCount:  2,
+		StoragePath:   "tcp://server/erp",
+		Storage:       models.Storage{StoragePassword: "storage-secret"},
+		Extension:     models.Extension{ExtensionName: "Sales", StoragePassword: "extension-secret"},
+	}
+	project := &models.Project{Name: "erp", CommitMessage: &models.CommitMessage{
+		Subject: "[{{.Source}}] {{firstLine .Comment}} ({{.Version}}, {{.Label}}, {{.ConfigVersion}})",
+		Body:    "{{.Project}} {{.StorageUser}} {{.User.GitUser}} {{.CreatedAt.Format \"2006-01-02 15:04\"}} +{{.AddedCount}} ~{{.ChangedCount}} {{.StoragePath}} {{.Extension.ExtensionName}}",
+	}}
+	messages, err := NewCommitMessages(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, body, err := messages.Render(version)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if want := "[Sales] Fix posting (7, v2.0, 2.0.1)"; subject != want {
+		t.Errorf("subject = %q, want %q", subject, want)
+	}
+	if want := "erp ivan Ivan Ivanov 2024-05-06 14:30 +1 ~2 tcp://server/erp Sales"; body != want {
+		t.Errorf("body = %q, want %q", body, want)
+	}
+
+	// Credentials of the storages are not part of the template data.
+	for _, text := range []string{"{{.Storage.StoragePassword}}", "{{.Extension.StoragePassword}}", "{{.ReportVersion.Storage}}"} {
+		project.CommitMessage = &models.CommitMessage{Subject: text}
+		messages, err := NewCommitMessages(project)
+		if err != nil {
+			t.Fatal(err)
+		}
+		subject, _, err := messages.Render(version)
+		if err == nil {
+			t.Errorf("template %s rendered to %q, want an error", text, subject)
+		}
+		if strings.Contains(subject, "secret") {
+			t.Errorf("template %s exposed a password: %q", text, subject)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"storage_to_git/logging"
+	"storage_to_git/models"
+)
+
+// ErrTimeout is returned when a platform process exceeds its configured timeout.
+var ErrTimeout = errors.New("operation timed out")
+
+const (
+	opReport = "report"
+	opUnbind = "unbind"
+	opUpdate = "update"
+	opDump   = "dump"
+	opRac    = "rac"
+
+	opGitCommit = "git_commit"
+	opGitPush   = "git_push"
+)
+
+// processWaitDelay bounds how long output pipes are drained after the process tree is killed.
+const processWaitDelay = 10 * time.Second
+
+type operationTimeouts map[string]time.Duration
+
+func parseTimeouts(config *models.Timeouts) (operationTimeouts, error) {
+	timeouts := make(operationTimeouts)
+	if config == nil {
+		return timeouts, nil
+	}
+
+	values := map[string]string{
+		"":       config.Default,
+		opReport: config.Report,
+		opUnbind: config.Unbind,
+		opUpdate: config.Update,
+		opDump:   config.Dump,
+		opRac:    config.Rac,
+	}
+	for operation, value := range values {
+		if value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s timeout %q: %w", operation, value, err)
+		}
+		timeouts[operation] = d
+	}
+
+	return timeouts, nil
+}
+
+// withTimeout derives the context for an operation, falling back to the default timeout.
+func (t operationTimeouts) withTimeout(ctx context.Context, operation string) (context.Context, context.CancelFunc) {
+	d, ok := t[operation]
+	if !ok {
+		d = t[""]
+	}
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+func executeCommand(ctx context.Context, name, logFilePath, dumpResultPath string, arg ...string) ([]byte, error, bool) {
+	logger := models.FromContext(ctx)
+
+	output, err := runProcess(ctx, name, arg...)
+	Log1C(logger, logFilePath)
+
+	hasError := CheckForErrors(logger, dumpResultPath)
+	if err != nil {
+		return output, err, hasError
+	}
+
+	logger.Info("Command executed successfully", "output", string(output))
+	return output, nil, hasError
+}
+
+// secretFlags are options whose next argument is a password or a permission code.
+var secretFlags = map[string]bool{
+	"/P":                        true,
+	"/ConfigurationRepositoryP": true,
+	"/UC":                       true,
+}
+
+// secretOptions are options that carry a password or a permission code after "=".
+var secretOptions = []string{"--password=", "--repository-password=", "--cluster-pwd=", "--infobase-pwd=", "--permission-code="}
+
+// commandString returns the command line for logging with password values masked.
+func commandString(name string, args []string) string {
+	masked := make([]string, 0, len(args)+1)
+	masked = append(masked, name)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if secretFlags[arg] && i+1 < len(args) {
+			masked = append(masked, arg, logging.Mask)
+			i++
+			continue
+		}
+		for _, option := range secretOptions {
+			if strings.HasPrefix(arg, option) {
+				arg = option + logging.Mask
+				break
+			}
+		}
+		masked = append(masked, arg)
+	}
+	return strings.Join(masked, " ")
+}
+
+// runProcess runs a platform utility bound to ctx. When ctx is done the whole process tree
+// is killed, so modal dialogs or license waits in child processes do not outlive the run.
+func runProcess(ctx context.Context, name string, arg ...string) ([]byte, error) {
+	logger := models.FromContext(ctx)
+
+	cmd := exec.CommandContext(ctx, name, arg...)
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return killProcessTree(cmd)
+	}
+	cmd.WaitDelay = processWaitDelay
+
+	logger.Debug("Executing command", "command", commandString(name, arg))
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			logger.Error("Command timed out", "command", name, "output", string(output))
+			return output, fmt.Errorf("%s: %w", name, ErrTimeout)
+		}
+		if ctx.Err() != nil {
+			logger.Error("Command cancelled", "command", name, "output", string(output))
+			return output, fmt.Errorf("%s: %w", name, ctx.Err())
+		}
+		logger.Error("Failed to execute command", "error", err, "output", string(output))
+		return output, fmt.Errorf("command execution failed: %w", err)
+	}
+
+	return output, nil
+}
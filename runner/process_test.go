@@ -0,0 +1,53 @@
+package runner
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCommandStringMasksPasswords(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "designer",
+			args: []string{"DESIGNER", "/N", "admin", "/P", "ib-secret", "/ConfigurationRepositoryN", "u", "/ConfigurationRepositoryP", "repo-secret"},
+			want: "1cv8 DESIGNER /N admin /P ****** /ConfigurationRepositoryN u /ConfigurationRepositoryP ******",
+		},
+		{
+			name: "ibcmd",
+			args: []string{"infobase", "config", "--user=admin", "--password=ib-secret", "--repository-password=repo-secret"},
+			want: "1cv8 infobase config --user=admin --password=****** --repository-password=******",
+		},
+		{
+			name: "rac",
+			args: []string{"infobase", "info", "--cluster-user=admin", "--cluster-pwd=cl-secret", "--infobase-pwd=ib-secret"},
+			want: "1cv8 infobase info --cluster-user=admin --cluster-pwd=****** --infobase-pwd=******",
+		},
+		{
+			name: "permission code",
+			args: []string{"DESIGNER", "/UC", "uc-secret", "infobase", "update", "--sessions-deny=on", "--permission-code=uc-secret"},
+			want: "1cv8 DESIGNER /UC ****** infobase update --sessions-deny=on --permission-code=******",
+		},
+		{
+			name: "flag without value",
+			args: []string{"DESIGNER", "/P"},
+			want: "1cv8 DESIGNER /P",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := commandString("1cv8", tt.args)
+			if got != tt.want {
+				t.Errorf("commandString() = %q, want %q", got, tt.want)
+			}
+			for _, secret := range []string{"ib-secret", "repo-secret", "cl-secret", "uc-secret"} {
+				if strings.Contains(got, secret) {
+					t.Errorf("secret %q not masked in %q", secret, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package runner
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"storage_to_git/git"
+	"storage_to_git/metrics"
+	"storage_to_git/models"
+)
+
+// A failed push to a remote is retried by later runs no sooner than pushRetryMin after the
+// failure; the delay doubles with each further failure up to pushRetryMax.
+const (
+	pushRetryMin = time.Minute
+	pushRetryMax = time.Hour
+)
+
+// RemotePush is the push status of a remote.
+type RemotePush struct {
+	Remote string
+	// Unpushed is the number of commits of the project branch the remote does not have.
+	Unpushed int
+	// Failures counts the failed pushes since the last successful one.
+	Failures    int
+	LastError   string
+	LastFailure time.Time
+	NextAttempt time.Time
+}
+
+// PushState keeps the push status of the remotes of a project between runs, so failed pushes
+// are retried even when no new versions arrive. It is safe for concurrent use.
+type PushState struct {
+	mu      sync.Mutex
+	remotes map[string]*RemotePush
+}
+
+func NewPushState() *PushState {
+	return &PushState{remotes: make(map[string]*RemotePush)}
+}
+
+// Remotes returns the status of the remotes, ordered by name.
+func (s *PushState) Remotes() []RemotePush {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	remotes := make([]RemotePush, 0, len(s.remotes))
+	for _, remote := range s.remotes {
+		remotes = append(remotes, *remote)
+	}
+	sort.Slice(remotes, func(i, j int) bool { return remotes[i].Remote < remotes[j].Remote })
+	return remotes
+}
+
+func (s *PushState) remote(name string) *RemotePush {
+	remote, ok := s.remotes[name]
+	if !ok {
+		remote = &RemotePush{Remote: name}
+		s.remotes[name] = remote
+	}
+	return remote
+}
+
+// retry reports whether the last push to the remote failed and when it may be retried.
+func (s *PushState) retry(name string) (failed bool, nextAttempt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	remote, ok := s.remotes[name]
+	if !ok || remote.Failures == 0 {
+		return false, time.Time{}
+	}
+	return true, remote.NextAttempt
+}
+
+func (s *PushState) failed(name string, err error, now time.Time) RemotePush {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	remote := s.remote(name)
+	remote.Failures++
+	remote.LastError = err.Error()
+	remote.LastFailure = now
+	delay := pushRetryMin
+	for i := 1; i < remote.Failures && delay < pushRetryMax; i++ {
+		delay *= 2
+	}
+	remote.NextAttempt = now.Add(min(delay, pushRetryMax))
+	return *remote
+}
+
+func (s *PushState) succeeded(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	remote := s.remote(name)
+	remote.Failures = 0
+	remote.LastError = ""
+	remote.LastFailure = time.Time{}
+	remote.NextAttempt = time.Time{}
+}
+
+func (s *PushState) setUnpushed(name string, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.remote(name).Unpushed = count
+}
+
+// retain drops the remotes that are no longer pushed to.
+func (s *PushState) retain(names map[string]bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name := range s.remotes {
+		if !names[name] {
+			delete(s.remotes, name)
+		}
+	}
+}
+
+type pushStateKey struct{}
+
+// WithPushState attaches the push status that Run updates and uses to retry failed pushes.
+// Without it every run starts with a fresh status.
+func WithPushState(ctx context.Context, state *PushState) context.Context {
+	return context.WithValue(ctx, pushStateKey{}, state)
+}
+
+func pushStateFrom(ctx context.Context) *PushState {
+	if state, ok := ctx.Value(pushStateKey{}).(*PushState); ok {
+		return state
+	}
+	return NewPushState()
+}
+
+// syncRemotes adds the enabled remotes of the project to the repository and updates the URLs
+// changed in the config. Remotes that are not in the config are left alone.
+func syncRemotes(logger *slog.Logger, repo git.Repository, project *models.Project) {
+	for _, remote := range project.Remotes() {
+		if !remote.Enabled {
+			continue
+		}
+		if err := repo.SetRemote(logger, remote.Name, remote.URL); err != nil {
+			logger.Error("Failed to configure git remote", "remote", remote.Name, "error", err)
+		}
+	}
+}
+
+// pushRemotes pushes the project branch and, if tags is set, all tags to the enabled remotes.
+// A failure on one remote does not prevent pushing to the others. A remote whose last push
+// failed is skipped until its retry is due and then gets the tags as well. With onlyBehind set,
+// only remotes with a failed push or unpushed commits are pushed to.
+func pushRemotes(logger *slog.Logger, repo git.Repository, project *models.Project, state *PushState, tags, onlyBehind bool) {
+	now := time.Now()
+	for _, remote := range project.Remotes() {
+		if !remote.Enabled || !remote.PushBranches && !remote.PushTags {
+			continue
+		}
+		failed, nextAttempt := state.retry(remote.Name)
+		if onlyBehind && !failed && unpushed(logger, repo, project, remote) == 0 {
+			continue
+		}
+		if now.Before(nextAttempt) {
+			logger.Info("Push postponed after a failure", "remote", remote.Name, "next_attempt", nextAttempt)
+			continue
+		}
+		if failed {
+			logger.Info("Retrying failed push", "remote", remote.Name)
+		}
+
+		err := pushRemote(logger, repo, project, remote, tags || failed)
+		if err != nil {
+			status := state.failed(remote.Name, err, now)
+			logger.Error("Git push failed", "repo", project.GitRepositoryPath, "remote", remote.Name,
+				"failures", status.Failures, "next_attempt", status.NextAttempt, "error", err)
+			continue
+		}
+		state.succeeded(remote.Name)
+	}
+}
+
+func pushRemote(logger *slog.Logger, repo git.Repository, project *models.Project, remote models.Remote, tags bool) error {
+	if remote.PushBranches {
+		start := time.Now()
+		err := repo.Push(logger, remote.Name, project.BranchName)
+		metrics.OperationDuration.Since(start, project.Name, opGitPush)
+		if err != nil {
+			return err
+		}
+	}
+	if tags && remote.PushTags {
+		return repo.PushTags(logger, remote.Name)
+	}
+	return nil
+}
+
+// unpushed returns the number of commits of the project branch missing on the remote, or 0
+// for a remote that does not receive the branch.
+func unpushed(logger *slog.Logger, repo git.Repository, project *models.Project, remote models.Remote) int {
+	if !remote.PushBranches {
+		return 0
+	}
+	count, err := repo.Unpushed(remote.Name, project.BranchName)
+	if err != nil {
+		logger.Warn("Failed to count unpushed commits", "remote", remote.Name, "error", err)
+	}
+	return count
+}
+
+// reportUnpushed records and logs the commits that did not reach the remotes of the project.
+func reportUnpushed(logger *slog.Logger, repo git.Repository, project *models.Project, state *PushState) {
+	names := make(map[string]bool)
+	for _, remote := range project.Remotes() {
+		if !remote.Enabled || !remote.PushBranches && !remote.PushTags {
+			continue
+		}
+		names[remote.Name] = true
+		count := unpushed(logger, repo, project, remote)
+		state.setUnpushed(remote.Name, count)
+		metrics.UnpushedCommits.Set(float64(count), project.Name, remote.Name)
+		if count > 0 {
+			logger.Warn("Commits not pushed to remote", "remote", remote.Name, "unpushed_commits", count)
+		}
+	}
+	state.retain(names)
+}
@@ -0,0 +1,221 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"storage_to_git/git"
+	"storage_to_git/models"
+	"storage_to_git/storage"
+	"storage_to_git/workspace"
+)
+
+// rebuildSuffix is appended to the default branch name and to the versions file of a rebuild.
+const rebuildSuffix = "-rebuild"
+
+// RebuildTarget is where a rebuild writes the new history: a new branch of the project
+// repository or, if RepositoryPath is set, a new repository directory.
+type RebuildTarget struct {
+	Branch         string
+	RepositoryPath string
+}
+
+// TreeComparison compares the tree of a rebuilt commit with the commit of the same storage
+// version on the original branch. An empty hash means the version has no commit there.
+type TreeComparison struct {
+	Source  string
+	Version int
+	OldTree string
+	NewTree string
+}
+
+func (c TreeComparison) Match() bool {
+	return c.OldTree != "" && c.OldTree == c.NewTree
+}
+
+// Rebuild converts all storage versions of the project again into a fresh branch or repository,
+// starting from version zero, and compares the resulting trees with the original branch.
+// The original branch, its tags and the versions file of the project are left untouched;
+// nothing is pushed. The caller must hold the lock of the project workspace, because the rebuild
+// uses the service infobase, the reports and, in branch mode, the working tree of the project.
+func Rebuild(ctx context.Context, config *models.Config, project *models.Project, storageUsers []models.UserMapping, executor Executor, target RebuildTarget) ([]TreeComparison, error) {
+	logger := models.FromContext(ctx)
+
+	rebuilt, err := rebuildProject(logger, project, target)
+	if err != nil {
+		return nil, err
+	}
+
+	if target.RepositoryPath != "" {
+		entries, err := os.ReadDir(rebuilt.GitRepositoryPath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read rebuild repository directory: %w", err)
+		}
+		if len(entries) > 0 {
+			return nil, fmt.Errorf("rebuild repository directory %s is not empty", rebuilt.GitRepositoryPath)
+		}
+	} else {
+		repo, err := git.NewRepository(logger, rebuilt.GitBackend, rebuilt.GitRepositoryPath, rebuilt.GitRemoteUrl, repositorySettings(rebuilt))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open git repository %s: %w", rebuilt.GitRepositoryPath, err)
+		}
+		// The working tree is switched to the new branch, so changes in it would end up in the rebuild
+		// or be lost.
+		clean, err := repo.IsClean()
+		if err != nil {
+			return nil, err
+		}
+		if !clean {
+			return nil, fmt.Errorf("working tree %s has uncommitted changes, commit or discard them or rebuild into a new repository directory", rebuilt.GitRepositoryPath)
+		}
+		if err := repo.CreateOrphanBranch(logger, rebuilt.BranchName); err != nil {
+			return nil, err
+		}
+		// Leave the working tree on the original branch for the regular runs, without the files an
+		// interrupted rebuild did not commit.
+		defer func() {
+			if err := repo.DiscardChanges(logger); err != nil {
+				logger.Error("Failed to clean the working tree after the rebuild", "error", err)
+				return
+			}
+			if err := repo.Checkout(logger, project.BranchName); err != nil {
+				logger.Error("Failed to switch back to the project branch", "branch", project.BranchName, "error", err)
+			}
+		}()
+	}
+
+	versions := make(models.VersionMap)
+	if rebuilt.Storage != nil {
+		versions["cf"] = 0
+	}
+	for _, ext := range rebuilt.Extensions {
+		versions[ext.ExtensionName] = 0
+	}
+	versionFile := workspace.New(rebuilt).VersionsFile()
+	if err := storage.SaveVersions(versionFile, versions); err != nil {
+		return nil, fmt.Errorf("failed to reset versions: %w", err)
+	}
+
+	logger.Info("Rebuilding history", "repo", rebuilt.GitRepositoryPath, "branch", rebuilt.BranchName, "versions_file", versionFile)
+	if err := Run(ctx, config, rebuilt, storageUsers, executor); err != nil {
+		return nil, err
+	}
+
+	return compareTrees(project, rebuilt)
+}
+
+// rebuildProject returns a copy of the project that writes to the rebuild target.
+func rebuildProject(logger *slog.Logger, project *models.Project, target RebuildTarget) (*models.Project, error) {
+	versionFile := workspace.New(project).VersionsFile()
+	ext := filepath.Ext(versionFile)
+
+	rebuilt := *project
+	rebuilt.GitPushEnabled = false
+	rebuilt.GitRemoteUrl = ""
+	rebuilt.GitRemotes = nil
+	rebuilt.VersionsFilePath = strings.TrimSuffix(versionFile, ext) + rebuildSuffix + ext
+	if project.Storage != nil {
+		s := *project.Storage
+		rebuilt.Storage = &s
+	}
+	rebuilt.Extensions = append([]models.Extension(nil), project.Extensions...)
+
+	if target.RepositoryPath != "" {
+		if ComparePaths(logger, target.RepositoryPath, project.GitRepositoryPath) {
+			return nil, errors.New("rebuild repository directory must differ from git_repository_path")
+		}
+		rebuilt.GitRepositoryPath = target.RepositoryPath
+		if target.Branch != "" {
+			rebuilt.BranchName = target.Branch
+		}
+		return &rebuilt, nil
+	}
+
+	rebuilt.BranchName = target.Branch
+	if rebuilt.BranchName == "" {
+		rebuilt.BranchName = project.BranchName + rebuildSuffix
+	}
+	if rebuilt.BranchName == project.BranchName {
+		return nil, errors.New("rebuild branch must differ from branch_name")
+	}
+	// Tags share the repository with the original branch, so they get the branch as namespace.
+	if rebuilt.Storage != nil {
+		rebuilt.Storage.TagPrefix = rebuilt.BranchName + "/" + rebuilt.Storage.TagPrefix
+	}
+	for i := range rebuilt.Extensions {
+		rebuilt.Extensions[i].TagPrefix = rebuilt.BranchName + "/" + rebuilt.Extensions[i].TagPrefix
+	}
+	return &rebuilt, nil
+}
+
+// compareTrees pairs the rebuilt commits with the original commits by storage version trailers,
+// in the order of the rebuilt branch, followed by original versions missing from the rebuild.
+func compareTrees(project, rebuilt *models.Project) ([]TreeComparison, error) {
+	oldRepo, err := git.OpenRepository(project.GitBackend, project.GitRepositoryPath, project.GitRemoteUrl, git.Settings{})
+	if err != nil {
+		return nil, err
+	}
+	newRepo, err := git.OpenRepository(rebuilt.GitBackend, rebuilt.GitRepositoryPath, rebuilt.GitRemoteUrl, git.Settings{})
+	if err != nil {
+		return nil, err
+	}
+
+	oldCommits, err := oldRepo.Log(project.BranchName, trailerStorageVersion, trailerExtension)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read original branch: %w", err)
+	}
+	newCommits, err := newRepo.Log(rebuilt.BranchName, trailerStorageVersion, trailerExtension)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rebuilt branch: %w", err)
+	}
+
+	type versionKey struct {
+		source  string
+		version int
+	}
+	keyOf := func(commit git.Commit) (versionKey, bool) {
+		version, err := strconv.Atoi(commit.Trailers[trailerStorageVersion])
+		if err != nil {
+			return versionKey{}, false
+		}
+		source := "cf"
+		if extension := commit.Trailers[trailerExtension]; extension != "" {
+			source = extension
+		}
+		return versionKey{source, version}, true
+	}
+
+	oldTrees := make(map[versionKey]string)
+	var oldOrder []versionKey
+	for i := len(oldCommits) - 1; i >= 0; i-- {
+		if key, ok := keyOf(oldCommits[i]); ok {
+			if _, seen := oldTrees[key]; !seen {
+				oldOrder = append(oldOrder, key)
+			}
+			oldTrees[key] = oldCommits[i].Tree
+		}
+	}
+
+	var result []TreeComparison
+	rebuiltKeys := make(map[versionKey]bool)
+	for i := len(newCommits) - 1; i >= 0; i-- {
+		key, ok := keyOf(newCommits[i])
+		if !ok {
+			continue
+		}
+		rebuiltKeys[key] = true
+		result = append(result, TreeComparison{Source: key.source, Version: key.version, OldTree: oldTrees[key], NewTree: newCommits[i].Tree})
+	}
+	for _, key := range oldOrder {
+		if !rebuiltKeys[key] {
+			result = append(result, TreeComparison{Source: key.source, Version: key.version, OldTree: oldTrees[key]})
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,72 @@
+package runner
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"storage_to_git/models"
+)
+
+func TestRebuildBranch(t *testing.T) {
+	project := newTestProject(t)
+	ctx := models.WithLogger(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err := Run(ctx, &models.Config{}, project, testUsers, newTestExecutor(project)); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	head := gitOutput(t, project.GitRepositoryPath, "rev-parse", "HEAD")
+
+	comparisons, err := Rebuild(ctx, &models.Config{}, project, testUsers, newTestExecutor(project), RebuildTarget{})
+	if err != nil {
+		t.Fatalf("Rebuild: %v", err)
+	}
+	if len(comparisons) != 3 {
+		t.Fatalf("got %d comparisons, want 3", len(comparisons))
+	}
+	for _, c := range comparisons {
+		if !c.Match() {
+			t.Errorf("version %d of %s differs: %+v", c.Version, c.Source, c)
+		}
+	}
+	if branch := gitOutput(t, project.GitRepositoryPath, "branch", "--show-current"); branch != "main\n" {
+		t.Errorf("working tree left on branch %s", branch)
+	}
+	if again := gitOutput(t, project.GitRepositoryPath, "rev-parse", "HEAD"); again != head {
+		t.Errorf("project branch moved from %s to %s", head, again)
+	}
+
+	// A tree with changes is not switched to the rebuild branch.
+	writeTestFile(t, filepath.Join(project.GitRepositoryPath, "notes.txt"), "work in progress")
+	if _, err := Rebuild(ctx, &models.Config{}, project, testUsers, newTestExecutor(project), RebuildTarget{Branch: "again"}); err == nil {
+		t.Fatal("Rebuild succeeded on a working tree with changes")
+	}
+	if branches := gitOutput(t, project.GitRepositoryPath, "branch", "--list", "again"); branches != "" {
+		t.Errorf("rebuild branch created on a working tree with changes: %s", branches)
+	}
+	if err := os.Remove(filepath.Join(project.GitRepositoryPath, "notes.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed dump leaves files behind, which must not be carried to the project branch.
+	fake := newTestExecutor(project)
+	fake.AddDump("cf", "2", map[string]string{"Configuration.xml": "<v2/>", "Configuration.xml/broken": ""})
+	if _, err := Rebuild(ctx, &models.Config{}, project, testUsers, fake, RebuildTarget{Branch: "failed"}); err == nil {
+		t.Fatal("Rebuild succeeded although the dump of version 2 failed")
+	}
+	if branch := gitOutput(t, project.GitRepositoryPath, "branch", "--show-current"); branch != "main\n" {
+		t.Errorf("working tree left on branch %s after a failed rebuild", branch)
+	}
+	if status := gitOutput(t, project.GitRepositoryPath, "status", "--porcelain"); status != "" {
+		t.Errorf("working tree has changes after a failed rebuild:\n%s", status)
+	}
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -2,292 +2,215 @@ package runner
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
-	"unicode"
 
 	"storage_to_git/git"
+	"storage_to_git/metrics"
 	"storage_to_git/models"
+	"storage_to_git/storage"
+	"storage_to_git/workspace"
 )
 
-func getNBeginFlag(version int) string {
-	if version > 0 {
-		return fmt.Sprintf("-NBegin %d", version+1)
-	}
-	return ""
-}
+// ErrStopped is returned when Run left early at a safe point because a stop was requested.
+var ErrStopped = errors.New("run stopped before all versions were processed")
 
-func Run(ctx context.Context, config *models.Config, project *models.Project, storageUsers []models.UserMapping) {
-	logger := models.FromContext(ctx)
+// ErrVersionsFailed is returned when some versions could not be committed. The run still
+// processes the remaining versions.
+var ErrVersionsFailed = errors.New("versions failed")
 
-	v8path := config.Catalog1cv8
-	if project.Catalog1cv8 != "" {
-		v8path = project.Catalog1cv8
-	}
-	v8files := NewV8Files(v8path)
+// Run converts new storage versions of the project into git commits.
+// It returns ErrTimeout (wrapped) when a 1C process exceeds its timeout.
+func Run(ctx context.Context, config *models.Config, project *models.Project, storageUsers []models.UserMapping, executor Executor) error {
+	logger := models.FromContext(ctx)
 
-	logger.Info("Running commands for project", "1cv8_path", v8files.ThickClient)
+	logger.Info("Running commands for project")
 
-	ibUser := &IBUser{
-		Name:     project.InfoBase.InfoBaseUser,
-		Password: project.InfoBase.InfoBasePassword,
+	timeouts, err := parseTimeouts(project.Timeouts)
+	if err != nil {
+		return err
 	}
 
-	infobase := &Infobase{
-		Path:           project.InfoBase.InfoBasePath,
-		User:           ibUser,
+	messages, err := NewCommitMessages(project)
+	if err != nil {
+		return err
 	}
 
-	logFilePath := filepath.Join(filepath.Dir(project.ProjectDataPath), project.V8LogFilePath)
-	dumpFilePath := getDumpFilePath(logFilePath)
-
-	versionFilePath := filepath.Join(project.ProjectDataPath, project.VersionsFilePath)
-	versionMap, err := readVersionsConfig(versionFilePath)
+	mainRepo, err := git.NewRepository(logger, project.GitBackend, project.GitRepositoryPath, project.GitRemoteUrl, repositorySettings(project))
 	if err != nil {
-		logger.Error("Failed to read versions config", "error", err)
-		return
+		return fmt.Errorf("failed to initialize main git repository %s: %w", project.GitRepositoryPath, err)
 	}
-
-	if project.Storage != nil {
-
-		storageUser := &StorageUser{
-			Name:     project.Storage.StorageUser,
-			Password: project.Storage.StoragePassword,
-		}
-
-		storage := &Storage{
-			Path:           project.Storage.StoragePath,
-			User:           storageUser,
-		}
-
-		reportFilePath := filepath.Join(filepath.Dir(project.ProjectDataPath), "cf.report")
-		nbeginFlag := getNBeginFlag(versionMap["cf"])
-
-		commandLine := fmt.Sprintf("DESIGNER /DisableStartupDialogs %s %s /ConfigurationRepositoryReport %q %s -ReportFormat txt /OUT %q /DumpResult %q", infobase.ConnectionString(), storage.ConnectionString(), reportFilePath, nbeginFlag, logFilePath, dumpFilePath)
-		logger.Info("Executing configuration repository report command")
-		args := splitCommandLine(commandLine)
-		_, err, hasError := executeCommand(logger, v8files.ThickClient, logFilePath, args...)
-		if err != nil || hasError {
-			logger.Error("Command execution failed", "error", err)
-			return
-		}
+	syncRemotes(logger, mainRepo, project)
+
+	pushes := pushStateFrom(ctx)
+	pushAtEnd := false
+	if project.GitPushEnabled {
+		// Pushing is deferred so the commits of a run that fails later are pushed as well, and
+		// a run without new versions still retries failed pushes and pushes commits that
+		// earlier runs left behind. A cancelled run leaves them to the next one.
+		defer func() {
+			if ctx.Err() != nil {
+				return
+			}
+			if pushAtEnd {
+				logger.Info("Pushing all modified repositories at the end", "repo", project.GitRepositoryPath)
+			}
+			pushRemotes(logger, mainRepo, project, pushes, true, !pushAtEnd)
+			reportUnpushed(logger, mainRepo, project, pushes)
+		}()
 	}
 
-	for _, ext := range project.Extensions {
-
-		extensionUser := &StorageUser{
-			Name:     ext.StorageUser,
-			Password: ext.StoragePassword,
-		}
-
-		extension := &Storage{
-			Path:           ext.StoragePath,
-			User:           extensionUser,
-		}
-
-		reportFilePath := filepath.Join(filepath.Dir(project.ProjectDataPath), fmt.Sprintf("%s.report", ext.ExtensionName))
-		nbeginFlag := getNBeginFlag(versionMap[ext.ExtensionName])
-
-		commandLine := fmt.Sprintf("DESIGNER /DisableStartupDialogs %s %s /ConfigurationRepositoryReport %q %s -ReportFormat txt -Extension %s /OUT %q /DumpResult %q", infobase.ConnectionString(), extension.ConnectionString(), reportFilePath, nbeginFlag, ext.ExtensionName, logFilePath, dumpFilePath)
-		logger.Info("Executing extension repository report command", "extension", ext.ExtensionName)
-		args := splitCommandLine(commandLine)
-		_, err, hasError := executeCommand(logger, v8files.ThickClient, logFilePath, args...)
-		if err != nil || hasError {
-			logger.Error("Command execution failed", "error", err)
-			return
+	if project.BranchName != "" {
+		err = mainRepo.Checkout(logger, project.BranchName)
+		if err != nil {
+			return fmt.Errorf("failed to checkout branch %s: %w", project.BranchName, err)
 		}
+	} else {
+		logger.Warn("Branch name is not specified for the project")
 	}
 
-	reports, err := processReports(logger, project.ProjectDataPath, storageUsers, project)
+	versionFilePath := workspace.New(project).VersionsFile()
+	versionMap, corrected, err := loadVersions(logger, versionFilePath, mainRepo, project)
 	if err != nil {
-		logger.Error("Failed to process reports", "error", err)
-		return
+		return err
 	}
-
-	allVersions := getAllVersions(reports)
-
-	filteredVersions := filterVersionsByConfig(logger, allVersions, versionMap)
-
-	sortVersionsByCreation(filteredVersions)
-
-	mainRepo, err := git.NewRepository(logger, project.GitRepositoryPath, project.GitRemoteUrl)
-	if err != nil {
-		logger.Error("Failed to initialize main git repository", "path", project.GitRepositoryPath, "error", err)
-		return
+	if corrected {
+		if err := storage.SaveVersions(versionFilePath, versionMap); err != nil {
+			return fmt.Errorf("failed to save versions config: %w", err)
+		}
 	}
 
-	if project.BranchName != "" {
-		err = mainRepo.Checkout(logger, project.BranchName)
+	// The infobase is locked after the push is deferred, so pending pushes are retried even when
+	// the cluster is unreachable.
+	if project.ClusterAdmin != nil && project.ClusterAdmin.Enabled {
+		release, err := LockInfobase(ctx, config, project)
 		if err != nil {
-			logger.Error("Failed to checkout branch", "branch", project.BranchName, "error", err)
-			return
+			return fmt.Errorf("failed to prepare service infobase on cluster: %w", err)
 		}
-	} else {
-		logger.Warn("Branch name is not specified for the project")
+		defer release()
 	}
 
-	pushNeeded := false
-	tagsPushed := false
+	filteredVersions, newest, err := collectVersions(ctx, project, storageUsers, executor, timeouts, versionMap)
+	if err != nil {
+		return err
+	}
+
+	stopped := false
+	var failedVersions []string
 
 	for _, version := range filteredVersions {
+		if models.StopRequested(ctx) {
+			logger.Info("Stop requested, leaving before next version", "version", version.Version)
+			stopped = true
+			break
+		}
+
 		logger.Info("Processing version", "version", version.Version)
 
-		commitDate := time.Date(
-			version.CreationDate.Year(),
-			version.CreationDate.Month(),
-			version.CreationDate.Day(),
-			version.CreationTime.Hour(),
-			version.CreationTime.Minute(),
-			version.CreationTime.Second(),
-			0,
-			version.CreationDate.Location(),
-		)
+		commitDate := version.CreatedAt()
 
-		commitSuccess := false
+		var storageUser *StorageUser
+		var storagePath, extensionName, gitDumpPath string
 
 		if version.Storage.StoragePath != "" {
 			logger.Info("Processing main configuration version", "version", version.Version)
 
-			storageUser := &StorageUser{
+			storageUser = &StorageUser{
 				Name:     version.Storage.StorageUser,
 				Password: version.Storage.StoragePassword,
 			}
-
-			storage := &Storage{
-				Path:           version.Storage.StoragePath,
-				User:           storageUser,
-			}
-
-			commandLine := fmt.Sprintf("DESIGNER /DisableStartupDialogs %s %s /ConfigurationRepositoryUnbindCfg -force /OUT %q /DumpResult %q", infobase.ConnectionString(), storage.ConnectionString(), logFilePath, dumpFilePath)
-			logger.Info("Executing unbind command")
-			args := splitCommandLine(commandLine)
-			_, err, hasError := executeCommand(logger, v8files.ThickClient, logFilePath, args...)
-			if err != nil || hasError {
-				logger.Error("Command unbind failed", "error", err)
-				return
-			}
-
-			commandLine = fmt.Sprintf("DESIGNER /DisableStartupDialogs %s %s /ConfigurationRepositoryUpdateCfg -v %s -force /OUT %q /DumpResult %q", infobase.ConnectionString(), storage.ConnectionString(), version.Version, logFilePath, dumpFilePath)
-			logger.Info("Executing update command")
-			args = splitCommandLine(commandLine)
-			_, err, hasError = executeCommand(logger, v8files.ThickClient, logFilePath, args...)
-			if err != nil || hasError {
-				logger.Error("Command update failed", "error", err)
-				return
-			}
-
-			gitDumpPath := filepath.Join(project.GitRepositoryPath, version.Storage.GitRepositoryPath)
-
-			if err := os.MkdirAll(gitDumpPath, os.ModePerm); err != nil {
-				logger.Error("Failed to create directory for git repository", "path", gitDumpPath, "error", err)
-				continue
-			}
-
-			dumpFlags := ""
-			dumpInfoPath := filepath.Join(gitDumpPath, "ConfigDumpInfo.xml")
-			if _, err := os.Stat(dumpInfoPath); err == nil {
-				dumpFlags = "-update -force"
-			} else if !os.IsNotExist(err) {
-				logger.Error("Error checking ConfigDumpInfo.xml", "path", dumpInfoPath, "error", err)
-			}
-
-			commandLine = fmt.Sprintf("DESIGNER /DisableStartupDialogs %s /DumpConfigToFiles %q %s /OUT %q /DumpResult %q", infobase.ConnectionString(), gitDumpPath, dumpFlags, logFilePath, dumpFilePath)
-			logger.Info("Executing dump to files command")
-			args = splitCommandLine(commandLine)
-			_, err, hasError = executeCommand(logger, v8files.ThickClient, logFilePath, args...)
-			if err != nil || hasError {
-				logger.Error("Command dump to files failed", "error", err)
-				return
-			}
-
-			logger.Info("Executing git commit")
-			currentBranch, err := mainRepo.GetCurrentBranch()
-			if err != nil {
-				logger.Error("Failed to get current branch", "error", err)
-			} else if currentBranch != project.BranchName {
-				logger.Error("Wrong branch before commit", "expected", project.BranchName, "current", currentBranch)
-			} else {
-				commitMade, err := mainRepo.Commit(logger, version.User.GitUser, version.User.GitEmail, version.Comment, commitDate)
-				if err != nil {
-					logger.Error("Git commit failed", "error", err)
-				} else {
-					commitSuccess = commitMade
-				}
-			}
-
+			storagePath = version.Storage.StoragePath
+			gitDumpPath = filepath.Join(project.GitRepositoryPath, version.Storage.GitRepositoryPath)
 		} else if version.Extension.StoragePath != "" {
 			logger.Info("Processing extension version", "extension", version.Extension.ExtensionName, "version", version.Version)
 
-			storageUser := &StorageUser{
+			storageUser = &StorageUser{
 				Name:     version.Extension.StorageUser,
 				Password: version.Extension.StoragePassword,
 			}
+			storagePath = version.Extension.StoragePath
+			extensionName = version.Extension.ExtensionName
+			gitDumpPath = filepath.Join(project.GitRepositoryPath, version.Extension.GitRepositoryPath, extensionName)
+		}
+
+		commitSuccess := false
 
+		if storagePath != "" {
 			storage := &Storage{
-				Path:           version.Extension.StoragePath,
-				User:           storageUser,
+				Path: storagePath,
+				User: storageUser,
 			}
 
-			extensionName := version.Extension.ExtensionName
-
-			commandLine := fmt.Sprintf("DESIGNER /DisableStartupDialogs %s %s /ConfigurationRepositoryUnbindCfg -force -Extension %s /OUT %q /DumpResult %q", infobase.ConnectionString(), storage.ConnectionString(), extensionName, logFilePath, dumpFilePath)
-			logger.Info("Executing unbind command for extension")
-			args := splitCommandLine(commandLine)
-			_, err, hasError := executeCommand(logger, v8files.ThickClient, logFilePath, args...)
-			if err != nil || hasError {
-				logger.Error("Command unbind failed", "error", err)
-				return
+			logger.Info("Executing unbind command", "extension", extensionName)
+			opCtx, cancel := timeouts.withTimeout(ctx, opUnbind)
+			start := time.Now()
+			err := executor.Unbind(opCtx, storage, extensionName)
+			metrics.OperationDuration.Since(start, project.Name, opUnbind)
+			cancel()
+			if err != nil {
+				return fmt.Errorf("unbind failed: %w", err)
 			}
 
-			commandLine = fmt.Sprintf("DESIGNER /DisableStartupStartupDialogs %s %s /ConfigurationRepositoryUpdateCfg -v %s -force -Extension %s /OUT %q /DumpResult %q", infobase.ConnectionString(), storage.ConnectionString(), version.Version, extensionName, logFilePath, dumpFilePath)
-			logger.Info("Executing update command for extension")
-			args = splitCommandLine(commandLine)
-			_, err, hasError = executeCommand(logger, v8files.ThickClient, logFilePath, args...)
-			if err != nil || hasError {
-				logger.Error("Command update failed", "error", err)
-				return
+			logger.Info("Executing update command", "extension", extensionName)
+			opCtx, cancel = timeouts.withTimeout(ctx, opUpdate)
+			start = time.Now()
+			err = executor.Update(opCtx, storage, extensionName, version.Version)
+			metrics.OperationDuration.Since(start, project.Name, opUpdate)
+			cancel()
+			if err != nil {
+				return fmt.Errorf("update to version %s failed: %w", version.Version, err)
 			}
 
-			gitDumpPath := filepath.Join(project.GitRepositoryPath, version.Extension.GitRepositoryPath, extensionName)
-
 			if err := os.MkdirAll(gitDumpPath, os.ModePerm); err != nil {
 				logger.Error("Failed to create directory for git repository", "path", gitDumpPath, "error", err)
+				failedVersions = append(failedVersions, version.Version)
 				continue
 			}
 
-			dumpFlags := ""
+			dumpUpdate := false
 			dumpInfoPath := filepath.Join(gitDumpPath, "ConfigDumpInfo.xml")
 			if _, err := os.Stat(dumpInfoPath); err == nil {
-				dumpFlags = "-update -force"
+				dumpUpdate = true
 			} else if !os.IsNotExist(err) {
 				logger.Error("Error checking ConfigDumpInfo.xml", "path", dumpInfoPath, "error", err)
 			}
 
-			commandLine = fmt.Sprintf("DESIGNER /DisableStartupDialogs %s /DumpConfigToFiles %q %s -Extension %s /OUT %q /DumpResult %q", infobase.ConnectionString(), gitDumpPath, dumpFlags, extensionName, logFilePath, dumpFilePath)
-			logger.Info("Executing dump to files command for extension")
-			args = splitCommandLine(commandLine)
-			_, err, hasError = executeCommand(logger, v8files.ThickClient, logFilePath, args...)
-			if err != nil || hasError {
-				logger.Error("Command dump to files failed", "error", err)
-				return
+			logger.Info("Executing dump to files command", "extension", extensionName)
+			opCtx, cancel = timeouts.withTimeout(ctx, opDump)
+			start = time.Now()
+			err = executor.DumpToFiles(opCtx, gitDumpPath, extensionName, dumpUpdate)
+			metrics.OperationDuration.Since(start, project.Name, opDump)
+			cancel()
+			if err != nil {
+				return fmt.Errorf("dump to files failed: %w", err)
 			}
 
-			logger.Info("Executing git commands")
+			logger.Info("Executing git commit")
 			currentBranch, err := mainRepo.GetCurrentBranch()
 			if err != nil {
 				logger.Error("Failed to get current branch", "error", err)
+				failedVersions = append(failedVersions, version.Version)
 			} else if currentBranch != project.BranchName {
 				logger.Error("Wrong branch before commit", "expected", project.BranchName, "current", currentBranch)
+				failedVersions = append(failedVersions, version.Version)
+			} else if subject, body, err := messages.Render(version); err != nil {
+				logger.Error("Failed to render commit message", "error", err)
+				failedVersions = append(failedVersions, version.Version)
 			} else {
-				commitMade, err := mainRepo.Commit(logger, version.User.GitUser, version.User.GitEmail, version.Comment, commitDate)
+				start = time.Now()
+				commitMade, err := mainRepo.Commit(logger, version.User.GitUser, version.User.GitEmail, commitMessage(subject, body, version), commitDate)
+				metrics.OperationDuration.Since(start, project.Name, opGitCommit)
 				if err != nil {
 					logger.Error("Git commit failed", "error", err)
+					failedVersions = append(failedVersions, version.Version)
 				} else {
 					commitSuccess = commitMade
 				}
@@ -295,102 +218,233 @@ func Run(ctx context.Context, config *models.Config, project *models.Project, st
 		}
 
 		if commitSuccess {
-			if version.Label != "" {
-				tagName := sanitizeTagName(version.Label)
-				if err := mainRepo.Tag(logger, tagName); err != nil {
+			metrics.VersionsCommitted.Inc(project.Name, workspace.ReportKey(version.FileName))
+			if tagName := TagName(version); tagName != "" {
+				if err := mainRepo.Tag(logger, tagName, tagMessage(version), version.User.GitUser, version.User.GitEmail, commitDate); err != nil {
 					logger.Error("Git tag failed", "tag", tagName, "error", err)
 				}
+			} else if version.Label != "" {
+				logger.Warn("Label has no characters allowed in a tag name, tag not created", "label", version.Label)
 			}
-			if project.GitPushEnabled {
-				pushNeeded = true
-				if project.GitPushTimingAfterEachCommit {
-					logger.Info("Pushing after each commit", "repo", mainRepo.Path)
-					if err := mainRepo.Push(logger, "origin", project.BranchName); err != nil {
-						logger.Error("Git push failed", "error", err)
-					}
-					if version.Label != "" {
-						if err := mainRepo.PushTags(logger, "origin"); err != nil {
-							logger.Error("Git push tags failed", "error", err)
-						}
-					}
-					tagsPushed = true
-				}
+			if project.GitPushEnabled && project.GitPushTimingAfterEachCommit {
+				logger.Info("Pushing after each commit", "repo", project.GitRepositoryPath)
+				pushRemotes(logger, mainRepo, project, pushes, version.Label != "", false)
+			} else if project.GitPushEnabled {
+				pushAtEnd = true
 			}
 		}
 
 		versionMap = updateVersionsConfig(logger, versionMap, version)
 
-		err = saveVersionsConfig(versionFilePath, versionMap)
+		err = storage.SaveVersions(versionFilePath, versionMap)
 		if err != nil {
-			logger.Error("Failed to save versions config", "error", err)
-			return
+			return fmt.Errorf("failed to save versions config: %w", err)
 		}
+		setVersionLag(project.Name, newest, versionMap)
 	}
 
-	if pushNeeded && project.GitPushEnabled && !project.GitPushTimingAfterEachCommit {
-		logger.Info("Pushing all modified repositories at the end", "repo", mainRepo.Path)
-		if err := mainRepo.Push(logger, "origin", project.BranchName); err != nil {
-			logger.Error("Git push failed", "repo", mainRepo.Path, "error", err)
-		}
-		if !tagsPushed {
-			if err := mainRepo.PushTags(logger, "origin"); err != nil {
-				logger.Error("Git push tags failed", "repo", mainRepo.Path, "error", err)
-			}
-		}
+	if len(failedVersions) > 0 {
+		return fmt.Errorf("%w: %s", ErrVersionsFailed, strings.Join(failedVersions, ", "))
+	}
+
+	if stopped {
+		return ErrStopped
 	}
 
 	logger.Info("Runner completed successfully")
+	return nil
 }
 
-var invalidTagChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+// Plan generates the repository reports and returns the versions the next run would commit,
+// in commit order. It changes neither the infobase configuration, nor git, nor the versions file.
+// The versions are taken from the versions file or its backup as they are: git is not run, so
+// they are not verified against the history of the project branch as in Run.
+func Plan(ctx context.Context, project *models.Project, storageUsers []models.UserMapping, executor Executor) ([]models.ReportVersion, error) {
+	timeouts, err := parseTimeouts(project.Timeouts)
+	if err != nil {
+		return nil, err
+	}
 
-func sanitizeTagName(name string) string {
-	// Replace spaces with hyphens
-	name = strings.ReplaceAll(name, " ", "-")
-	// Remove any other invalid characters
-	return invalidTagChars.ReplaceAllString(name, "")
+	versionMap, err := storage.LoadVersions(models.FromContext(ctx), workspace.New(project).VersionsFile())
+	if errors.Is(err, storage.ErrVersionsCorrupt) {
+		return nil, fmt.Errorf("%w; the next run recovers the versions from git history, which is not read in a dry run", err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read versions config: %w", err)
+	}
+
+	versions, _, err := collectVersions(ctx, project, storageUsers, executor, timeouts, versionMap)
+	return versions, err
 }
 
-func splitCommandLine(s string) []string {
-	var args []string
-	var current strings.Builder
-	inQuotes := false
-
-	for _, r := range s {
-		switch {
-		case r == '"':
-			inQuotes = !inQuotes
-		case unicode.IsSpace(r) && !inQuotes:
-			if current.Len() > 0 {
-				args = append(args, current.String())
-				current.Reset()
-			}
-		default:
-			current.WriteRune(r)
-		}
+// repositorySettings returns the committer and signing options of the project repository.
+func repositorySettings(project *models.Project) git.Settings {
+	var settings git.Settings
+	if c := project.GitCommitter; c != nil {
+		settings.CommitterName = c.Name
+		settings.CommitterEmail = c.Email
+		settings.CommitterDateFromAuthor = c.DateFromVersion
+	}
+	if s := project.GitSigning; s != nil {
+		settings.SigningFormat = s.Format
+		settings.SigningKey = s.Key
+		settings.SigningPassphrase = s.Passphrase
 	}
+	return settings
+}
 
-	if current.Len() > 0 {
-		args = append(args, current.String())
+// loadVersions reads the processed versions and verifies them against the commit trailers of
+// the project branch. If both the versions file and its backup are unreadable, the versions are
+// reconstructed from the trailers. It reports whether the result differs from the file.
+func loadVersions(logger *slog.Logger, versionFilePath string, repo git.Repository, project *models.Project) (models.VersionMap, bool, error) {
+	versionMap, err := storage.LoadVersions(logger, versionFilePath)
+	if err == nil {
+		verified, err := verifyVersions(logger, versionMap, repo, project)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to verify versions against git history: %w", err)
+		}
+		return verified, !maps.Equal(verified, versionMap), nil
+	}
+	if !errors.Is(err, storage.ErrVersionsCorrupt) {
+		return nil, false, fmt.Errorf("failed to read versions config: %w", err)
 	}
 
-	return args
+	logger.Warn("Recovering versions from git history", "error", err, "branch", project.BranchName)
+	versionMap, err = versionsFromHistory(logger, repo, project)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to recover versions from git history: %w", err)
+	}
+	logger.Warn("Versions recovered from git history", "versions", versionMap)
+	return versionMap, true, nil
 }
 
-func executeCommand(logger *slog.Logger, name, logFilePath string, arg ...string) ([]byte, error, bool) {
-	cmd := exec.Command(name, arg...)
+// collectVersions generates the repository reports and returns the versions newer than versionMap
+// sorted by creation time, together with the newest version in the reports per version key.
+func collectVersions(ctx context.Context, project *models.Project, storageUsers []models.UserMapping, executor Executor, timeouts operationTimeouts, versionMap models.VersionMap) ([]models.ReportVersion, map[string]int, error) {
+	logger := models.FromContext(ctx)
+	ws := workspace.New(project)
+	var reportFiles []string
+
+	if project.Storage != nil {
+
+		storageUser := &StorageUser{
+			Name:     project.Storage.StorageUser,
+			Password: project.Storage.StoragePassword,
+		}
+
+		storage := &Storage{
+			Path: project.Storage.StoragePath,
+			User: storageUser,
+		}
+
+		reportFilePath := ws.ReportFile("cf")
 
-	logger.Debug("Executing command", "command", cmd.String())
+		logger.Info("Executing configuration repository report command")
+		opCtx, cancel := timeouts.withTimeout(ctx, opReport)
+		start := time.Now()
+		err := executor.Report(opCtx, storage, "", reportFilePath, versionMap["cf"])
+		metrics.OperationDuration.Since(start, project.Name, opReport)
+		cancel()
+		if err != nil {
+			return nil, nil, fmt.Errorf("configuration repository report failed: %w", err)
+		}
+		reportFiles = append(reportFiles, reportFilePath)
+	}
 
-	output, err := cmd.CombinedOutput()
-	Log1C(logger, logFilePath)
+	for _, ext := range project.Extensions {
+
+		extensionUser := &StorageUser{
+			Name:     ext.StorageUser,
+			Password: ext.StoragePassword,
+		}
+
+		extension := &Storage{
+			Path: ext.StoragePath,
+			User: extensionUser,
+		}
 
-	hasError := CheckForErrors(logger, logFilePath)
+		reportFilePath := ws.ReportFile(ext.ExtensionName)
+
+		logger.Info("Executing extension repository report command", "extension", ext.ExtensionName)
+		opCtx, cancel := timeouts.withTimeout(ctx, opReport)
+		start := time.Now()
+		err := executor.Report(opCtx, extension, ext.ExtensionName, reportFilePath, versionMap[ext.ExtensionName])
+		metrics.OperationDuration.Since(start, project.Name, opReport)
+		cancel()
+		if err != nil {
+			return nil, nil, fmt.Errorf("extension %s repository report failed: %w", ext.ExtensionName, err)
+		}
+		reportFiles = append(reportFiles, reportFilePath)
+	}
+
+	reports, err := processReports(logger, reportFiles, storageUsers, project)
 	if err != nil {
-		logger.Error("Failed to execute command", "error", err, "output", string(output))
-		return output, fmt.Errorf("command execution failed: %w", err), hasError
+		return nil, nil, fmt.Errorf("failed to process reports: %w", err)
 	}
 
-	logger.Info("Command executed successfully", "output", string(output))
-	return output, nil, hasError
-}
\ No newline at end of file
+	allVersions := getAllVersions(reports)
+
+	newest := newestVersions(logger, allVersions)
+	setVersionLag(project.Name, newest, versionMap)
+
+	filteredVersions := filterVersionsByConfig(logger, allVersions, versionMap)
+
+	sortVersionsByCreation(filteredVersions)
+
+	return filteredVersions, newest, nil
+}
+
+// newestVersions returns the highest storage version found in the reports per version key.
+func newestVersions(logger *slog.Logger, versions []models.ReportVersion) map[string]int {
+	newest := make(map[string]int)
+	for _, version := range versions {
+		versionNum, err := strconv.Atoi(version.Version)
+		if err != nil {
+			logger.Error("error parsing version", "version", version.Version, "error", err)
+			continue
+		}
+		key := workspace.ReportKey(version.FileName)
+		if versionNum > newest[key] {
+			newest[key] = versionNum
+		}
+	}
+	return newest
+}
+
+func setVersionLag(projectName string, newest map[string]int, versionMap models.VersionMap) {
+	for key, versionNum := range newest {
+		metrics.VersionLag.Set(float64(max(versionNum-versionMap[key], 0)), projectName, key)
+	}
+}
+
+var invalidTagChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+// TagName returns the git tag created for the storage label of a version, with the tag prefix of
+// its storage or extension. It returns "" for a version without a label or when no character of
+// the label is allowed in a tag name.
+func TagName(version models.ReportVersion) string {
+	if version.Label == "" {
+		return ""
+	}
+	// Replace spaces with hyphens
+	name := strings.ReplaceAll(version.Label, " ", "-")
+	// Remove any other invalid characters
+	name = invalidTagChars.ReplaceAllString(name, "")
+	if name == "" {
+		return ""
+	}
+
+	prefix := version.Storage.TagPrefix
+	if version.Extension.ExtensionName != "" {
+		prefix = version.Extension.TagPrefix
+	}
+	return prefix + name
+}
+
+// tagMessage returns the annotation of a label tag: the label comment or, without one, the label.
+func tagMessage(version models.ReportVersion) string {
+	if message := strings.TrimSpace(version.LabelComment); message != "" {
+		return message
+	}
+	return version.Label
+}
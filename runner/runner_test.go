@@ -0,0 +1,265 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"storage_to_git/git"
+	"storage_to_git/models"
+	"storage_to_git/storage"
+	"storage_to_git/workspace"
+)
+
+const testReport = `Отчет по версиям хранилища: /stor/cf
+Дата отчета: 01.02.2024
+Время отчета: 10:00:00
+
+Версия: 1
+Пользователь: Ivan
+Дата создания: 01.01.2024
+Время создания: 10:00:00
+Комментарий: first
+Метка: v1.0
+Комментарий метки: release one
+Добавлены 2
+
+Версия: 2
+Версия конфигурации: 1.0.2
+Пользователь: Petr
+Дата создания: 02.01.2024
+Время создания: 11:00:00
+Комментарий: second
+
+Версия: 3
+Пользователь: Ivan
+Дата создания: 03.01.2024
+Время создания: 12:00:00
+Комментарий:
+`
+
+// newTestProject returns a project converting the storage of testReport into a new repository.
+func newTestProject(t *testing.T) *models.Project {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	dir := t.TempDir()
+	project := &models.Project{
+		Name:              "test",
+		ProjectDataPath:   filepath.Join(dir, "data"),
+		UsersFilePath:     "users.csv",
+		VersionsFilePath:  "versions.json",
+		V8LogFilePath:     "log.txt",
+		GitRepositoryPath: filepath.Join(dir, "repo"),
+		BranchName:        "main",
+		GitCommitter:      &models.GitCommitter{Name: "Converter", Email: "converter@example.com", DateFromVersion: true},
+		Storage: &models.Storage{
+			StoragePath:       "/stor/cf",
+			StorageUser:       "reader",
+			StoragePassword:   "secret",
+			GitRepositoryPath: filepath.Join("src", "cf"),
+		},
+	}
+	if err := os.MkdirAll(project.ProjectDataPath, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.SaveVersions(workspace.New(project).VersionsFile(), models.VersionMap{"cf": 0}); err != nil {
+		t.Fatal(err)
+	}
+	return project
+}
+
+var testUsers = []models.UserMapping{
+	{StorageUser: "Ivan", GitUser: "Ivan Ivanov", GitEmail: "ivanov@example.com"},
+	{StorageUser: "Petr", GitUser: "Petr Petrov", GitEmail: "petrov@example.com"},
+}
+
+func newTestExecutor(project *models.Project) *FakeExecutor {
+	fake := NewFakeExecutor(workspace.New(project).LogFile())
+	fake.Reports["cf"] = testReport
+	fake.AddDump("cf", "1", map[string]string{"Configuration.xml": "<v1/>"})
+	fake.AddDump("cf", "2", map[string]string{"Configuration.xml": "<v2/>", "CommonModules/Tools.bsl": "// tools"})
+	fake.AddDump("cf", "3", map[string]string{"Configuration.xml": "<v3/>"})
+	return fake
+}
+
+func TestRunConvertsVersions(t *testing.T) {
+	project := newTestProject(t)
+	fake := newTestExecutor(project)
+	ctx := models.WithLogger(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	if err := Run(ctx, &models.Config{}, project, testUsers, fake); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	var operations []string
+	for _, call := range fake.Calls {
+		operations = append(operations, call.Operation+" "+call.Version)
+	}
+	want := []string{"report 0", "unbind ", "update 1", "dump 1", "unbind ", "update 2", "dump 2", "unbind ", "update 3", "dump 3"}
+	if !reflect.DeepEqual(operations, want) {
+		t.Errorf("executor calls = %q, want %q", operations, want)
+	}
+
+	versions, err := storage.LoadVersions(slog.Default(), workspace.New(project).VersionsFile())
+	if err != nil {
+		t.Fatalf("LoadVersions: %v", err)
+	}
+	if !reflect.DeepEqual(versions, models.VersionMap{"cf": 3}) {
+		t.Errorf("versions.json = %v, want cf: 3", versions)
+	}
+
+	repo, err := git.OpenRepository(project.GitBackend, project.GitRepositoryPath, "", git.Settings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	commits, err := repo.Log(project.BranchName, trailerStorageVersion, trailerStorageUser, trailerConfigVersion)
+	if err != nil {
+		t.Fatalf("Log: %v", err)
+	}
+	if len(commits) != 3 {
+		t.Fatalf("got %d commits, want 3", len(commits))
+	}
+	for i, want := range []map[string]string{
+		{trailerStorageVersion: "3", trailerStorageUser: "Ivan"},
+		{trailerStorageVersion: "2", trailerStorageUser: "Petr", trailerConfigVersion: "1.0.2"},
+		{trailerStorageVersion: "1", trailerStorageUser: "Ivan"},
+	} {
+		if !reflect.DeepEqual(commits[i].Trailers, want) {
+			t.Errorf("commit %d trailers = %v, want %v", i, commits[i].Trailers, want)
+		}
+	}
+
+	log := gitOutput(t, project.GitRepositoryPath, "log", "--format=%an <%ae>|%s|%ad", "--date=format:%Y-%m-%d %H:%M")
+	wantLog := "Ivan Ivanov <ivanov@example.com>|Storage version 3|2024-01-03 12:00\n" +
+		"Petr Petrov <petrov@example.com>|second|2024-01-02 11:00\n" +
+		"Ivan Ivanov <ivanov@example.com>|first|2024-01-01 10:00\n"
+	if log != wantLog {
+		t.Errorf("git log =\n%s\nwant\n%s", log, wantLog)
+	}
+	if files := gitOutput(t, project.GitRepositoryPath, "ls-tree", "-r", "--name-only", "HEAD"); files != "src/cf/CommonModules/Tools.bsl\nsrc/cf/ConfigDumpInfo.xml\nsrc/cf/Configuration.xml\n" {
+		t.Errorf("files in HEAD:\n%s", files)
+	}
+	if tag := gitOutput(t, project.GitRepositoryPath, "tag", "-n1"); !strings.HasPrefix(tag, "v1.0") || !strings.Contains(tag, "release one") {
+		t.Errorf("tags = %q, want v1.0 annotated with the label comment", tag)
+	}
+
+	// A second run finds no new versions and changes nothing.
+	fake.Calls = nil
+	if err := Run(ctx, &models.Config{}, project, testUsers, fake); err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	if len(fake.Calls) != 1 || fake.Calls[0].Operation != opReport || fake.Calls[0].Version != "3" {
+		t.Errorf("second run calls = %+v, want a single report from version 3", fake.Calls)
+	}
+	if again := gitOutput(t, project.GitRepositoryPath, "rev-list", "--count", "HEAD"); again != "3\n" {
+		t.Errorf("second run made commits, HEAD has %s", again)
+	}
+}
+
+func TestRunStopsOnFailedUpdate(t *testing.T) {
+	project := newTestProject(t)
+	fake := newTestExecutor(project)
+	fake.Results[opUpdate] = []int{0, 1}
+	ctx := models.WithLogger(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	if err := Run(ctx, &models.Config{}, project, testUsers, fake); err == nil {
+		t.Fatal("Run succeeded although update to version 2 failed")
+	}
+	versions, err := storage.LoadVersions(slog.Default(), workspace.New(project).VersionsFile())
+	if err != nil {
+		t.Fatalf("LoadVersions: %v", err)
+	}
+	if versions["cf"] != 1 {
+		t.Errorf("versions.json = %v, want cf: 1", versions)
+	}
+	if count := gitOutput(t, project.GitRepositoryPath, "rev-list", "--count", "HEAD"); count != "1\n" {
+		t.Errorf("HEAD has %s commits, want 1", count)
+	}
+}
+
+func gitOutput(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v, output: %s", strings.Join(args, " "), err, output)
+	}
+	return string(output)
+}
+
+func TestPlanReadsOnlyVersionsFile(t *testing.T) {
+	project := newTestProject(t)
+	versionsFile := workspace.New(project).VersionsFile()
+	// Only the backup holds the versions, the repository does not exist.
+	if err := os.WriteFile(versionsFile+".bak", []byte(`{"cf": 1}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(versionsFile, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fake := newTestExecutor(project)
+	ctx := models.WithLogger(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	versions, err := Plan(ctx, project, testUsers, fake)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	var planned []string
+	for _, version := range versions {
+		planned = append(planned, version.Version)
+	}
+	if !reflect.DeepEqual(planned, []string{"2", "3"}) {
+		t.Errorf("planned versions = %q, want 2 and 3", planned)
+	}
+	if len(fake.Calls) != 1 || fake.Calls[0].Operation != opReport {
+		t.Errorf("executor calls = %+v, want a single report", fake.Calls)
+	}
+	if _, err := os.Stat(project.GitRepositoryPath); !os.IsNotExist(err) {
+		t.Errorf("Plan created the repository directory: %v", err)
+	}
+
+	if err := os.WriteFile(versionsFile+".bak", []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Plan(ctx, project, testUsers, fake); !errors.Is(err, storage.ErrVersionsCorrupt) {
+		t.Errorf("Plan with unreadable versions = %v, want ErrVersionsCorrupt", err)
+	}
+}
+
+func TestRunPushesWhenClusterIsUnreachable(t *testing.T) {
+	project := newTestProject(t)
+	ctx := models.WithLogger(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err := Run(ctx, &models.Config{}, project, testUsers, newTestExecutor(project)); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	// The commits of the first run are pending for the new remote, but rac cannot be started.
+	bare := filepath.Join(t.TempDir(), "remote.git")
+	gitOutput(t, project.GitRepositoryPath, "init", "--quiet", "--bare", bare)
+	project.GitPushEnabled = true
+	project.GitRemotes = []models.Remote{{Name: "backup", URL: bare, PushBranches: true, Enabled: true}}
+	project.InfoBase = models.InfoBase{InfoBaseServer: "srv", InfoBaseRef: "erp"}
+	project.ClusterAdmin = &models.ClusterAdmin{Enabled: true, TerminateSessions: true}
+	config := &models.Config{Catalog1cv8: t.TempDir()}
+
+	if err := Run(ctx, config, project, testUsers, newTestExecutor(project)); err == nil {
+		t.Fatal("Run succeeded without rac")
+	}
+	head := gitOutput(t, project.GitRepositoryPath, "rev-parse", "HEAD")
+	if pushed := gitOutput(t, bare, "rev-parse", "refs/heads/main"); pushed != head {
+		t.Errorf("remote main = %s, want %s", pushed, head)
+	}
+}
@@ -0,0 +1,124 @@
+package runner
+
+import (
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"storage_to_git/git"
+	"storage_to_git/models"
+)
+
+// Commit trailers record which storage version a commit was made from, so a commit can be traced
+// back to the storage and the processed versions can be reconstructed from the git history.
+const (
+	trailerStorageVersion = "Storage-Version"
+	trailerStoragePath    = "Storage-Path"
+	trailerExtension      = "Extension"
+	trailerConfigVersion  = "Config-Version"
+	trailerStorageUser    = "Storage-User"
+	trailerAdded          = "Storage-Added"
+	trailerChanged        = "Storage-Changed"
+)
+
+// commitMessage joins the rendered subject and body and appends the version trailers.
+func commitMessage(subject, body string, version models.ReportVersion) string {
+	var b strings.Builder
+	b.WriteString(subject)
+	b.WriteString("\n")
+	if body != "" {
+		b.WriteString("\n" + body + "\n")
+	}
+	for i, trailer := range versionTrailers(version) {
+		if i == 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(trailer[0] + ": " + trailer[1] + "\n")
+	}
+	return b.String()
+}
+
+// versionTrailers returns the trailer key-value pairs of a version, skipping empty values.
+func versionTrailers(version models.ReportVersion) [][2]string {
+	all := [][2]string{
+		{trailerStorageVersion, version.Version},
+		{trailerStoragePath, version.StoragePath},
+		{trailerExtension, version.Extension.ExtensionName},
+		{trailerConfigVersion, version.ConfigVersion},
+		{trailerStorageUser, version.StorageUser},
+		{trailerAdded, strconv.Itoa(version.AddedCount)},
+		{trailerChanged, strconv.Itoa(version.ChangedCount)},
+	}
+
+	var trailers [][2]string
+	for _, trailer := range all {
+		// Trailer values are single-line.
+		value := strings.Join(strings.Fields(trailer[1]), " ")
+		if value != "" {
+			trailers = append(trailers, [2]string{trailer[0], value})
+		}
+	}
+	return trailers
+}
+
+// versionsFromHistory returns the highest storage version committed on the branch per version key.
+// Commits made from another storage path than the one configured for the key are ignored.
+func versionsFromHistory(logger *slog.Logger, repo git.Repository, project *models.Project) (models.VersionMap, error) {
+	commits, err := repo.Log(project.BranchName, trailerStorageVersion, trailerStoragePath, trailerExtension)
+	if err != nil {
+		return nil, err
+	}
+
+	storagePaths := make(map[string]string)
+	if project.Storage != nil {
+		storagePaths["cf"] = project.Storage.StoragePath
+	}
+	for _, ext := range project.Extensions {
+		storagePaths[ext.ExtensionName] = ext.StoragePath
+	}
+
+	versions := make(models.VersionMap)
+	for _, commit := range commits {
+		trailers := commit.Trailers
+		versionNum, err := strconv.Atoi(trailers[trailerStorageVersion])
+		if err != nil {
+			continue
+		}
+		key := "cf"
+		if extension := trailers[trailerExtension]; extension != "" {
+			key = extension
+		}
+		if path := trailers[trailerStoragePath]; path != "" {
+			if configured, ok := storagePaths[key]; ok && !ComparePaths(logger, configured, path) {
+				continue
+			}
+		}
+		if versionNum > versions[key] {
+			versions[key] = versionNum
+		}
+	}
+	return versions, nil
+}
+
+// verifyVersions compares the versions file with the git history. A version committed to git
+// but missing from the file, e.g. after a crash between commit and save, is taken from the history
+// so it is not committed twice. The file may be ahead of the history: versions without changes
+// produce no commit.
+func verifyVersions(logger *slog.Logger, versionMap models.VersionMap, repo git.Repository, project *models.Project) (models.VersionMap, error) {
+	history, err := versionsFromHistory(logger, repo, project)
+	if err != nil {
+		return nil, err
+	}
+
+	verified := make(models.VersionMap)
+	for key, versionNum := range versionMap {
+		verified[key] = versionNum
+	}
+	for key, versionNum := range history {
+		if versionNum > verified[key] {
+			logger.Warn("Versions file is behind git history, using version from history", "key", key, "file", verified[key], "history", versionNum)
+			verified[key] = versionNum
+		}
+	}
+	return verified, nil
+}
@@ -0,0 +1,62 @@
+package runner
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"reflect"
+	"testing"
+
+	"storage_to_git/git"
+	"storage_to_git/models"
+)
+
+func TestVersionsFromHistory(t *testing.T) {
+	project := newTestProject(t)
+	project.Extensions = []models.Extension{{ExtensionName: "Sales", StoragePath: "/stor/sales"}}
+	dir := project.GitRepositoryPath
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	gitOutput(t, dir, "init", "-q", "-b", project.BranchName)
+
+	commit := func(message string) {
+		gitOutput(t, dir, "-c", "user.name=Test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", message)
+	}
+	version := func(number, storagePath, extension string) models.ReportVersion {
+		return models.ReportVersion{
+			Version:     number,
+			StoragePath: storagePath,
+			StorageUser: "Ivan",
+			Extension:   models.Extension{ExtensionName: extension},
+			AddedCount:  1,
+		}
+	}
+	commit(commitMessage("first", "", version("1", "/stor/cf", "")))
+	commit(commitMessage("sales", "body", version("4", "/stor/sales", "Sales")))
+	commit("Update README\n\nWritten by hand, no trailers.")
+	commit(commitMessage("third", "", version("3", "/stor/cf", "")))
+	// A commit from another storage, e.g. before storage_path was changed.
+	commit(commitMessage("old storage", "", version("9", "/stor/old", "")))
+	// An extension that is no longer configured is still read back.
+	commit(commitMessage("removed", "", version("2", "/stor/removed", "Removed")))
+	commit("Manual fix\n\nStorage-Version: next")
+	// A storage path written differently still matches the configured one.
+	commit(commitMessage("sales again", "", version("5", "/stor/sales/", "Sales")))
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	want := models.VersionMap{"cf": 3, "Sales": 5, "Removed": 2}
+	for _, backend := range []string{git.BackendExec, git.BackendGo} {
+		repo, err := git.OpenRepository(backend, dir, "", git.Settings{})
+		if err != nil {
+			t.Fatalf("%s: %v", backend, err)
+		}
+		got, err := versionsFromHistory(logger, repo, project)
+		if err != nil {
+			t.Fatalf("%s: versionsFromHistory: %v", backend, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: versionsFromHistory = %v, want %v", backend, got, want)
+		}
+	}
+}
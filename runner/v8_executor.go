@@ -0,0 +1,131 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"storage_to_git/models"
+	"storage_to_git/workspace"
+)
+
+// Executor performs 1C platform operations against the service infobase of a project.
+// Extension is empty for the main configuration.
+type Executor interface {
+	Report(ctx context.Context, storage *Storage, extension, reportFilePath string, lastVersion int) error
+	Unbind(ctx context.Context, storage *Storage, extension string) error
+	Update(ctx context.Context, storage *Storage, extension, version string) error
+	DumpToFiles(ctx context.Context, dumpPath, extension string, update bool) error
+}
+
+var errDumpResult = errors.New("1C reported an error in dump result")
+
+// NewExecutor returns the executor for the backend selected in the project.
+func NewExecutor(config *models.Config, project *models.Project) (Executor, error) {
+	switch project.Backend {
+	case "", models.BackendDesigner:
+		return NewDesignerExecutor(config, project)
+	case models.BackendIbcmd:
+		return NewIbcmdExecutor(config, project)
+	default:
+		return nil, fmt.Errorf("unknown backend %q", project.Backend)
+	}
+}
+
+// DesignerExecutor runs operations through the thick client in DESIGNER mode.
+type DesignerExecutor struct {
+	V8Files        *V8Files
+	Infobase       *Infobase
+	LogFilePath    string
+	DumpResultPath string
+	// VirtualDisplay starts Designer under xvfb-run, for hosts without an X display.
+	VirtualDisplay bool
+}
+
+func NewDesignerExecutor(config *models.Config, project *models.Project) (*DesignerExecutor, error) {
+	v8path := config.Catalog1cv8
+	if project.Catalog1cv8 != "" {
+		v8path = project.Catalog1cv8
+	}
+
+	infobase, err := NewInfobase(project.InfoBase)
+	if err != nil {
+		return nil, err
+	}
+	if project.ClusterAdmin != nil && project.ClusterAdmin.Enabled && project.ClusterAdmin.LockSessions {
+		infobase.PermissionCode = project.ClusterAdmin.PermissionCode
+	}
+
+	ws := workspace.New(project)
+	return &DesignerExecutor{
+		V8Files:        NewV8Files(v8path),
+		Infobase:       infobase,
+		LogFilePath:    ws.LogFile(),
+		DumpResultPath: ws.DumpResultFile(),
+	}, nil
+}
+
+func extensionArgs(extension string) []string {
+	if extension == "" {
+		return nil
+	}
+	return []string{"-Extension", extension}
+}
+
+func getNBeginArgs(version int) []string {
+	if version > 0 {
+		return []string{"-NBegin", strconv.Itoa(version + 1)}
+	}
+	return nil
+}
+
+func (e *DesignerExecutor) Report(ctx context.Context, storage *Storage, extension, reportFilePath string, lastVersion int) error {
+	args := append(e.Infobase.Args(), storage.Args()...)
+	args = append(args, "/ConfigurationRepositoryReport", reportFilePath)
+	args = append(args, getNBeginArgs(lastVersion)...)
+	args = append(args, "-ReportFormat", "txt")
+	args = append(args, extensionArgs(extension)...)
+	return e.run(ctx, args...)
+}
+
+func (e *DesignerExecutor) Unbind(ctx context.Context, storage *Storage, extension string) error {
+	args := append(e.Infobase.Args(), storage.Args()...)
+	args = append(args, "/ConfigurationRepositoryUnbindCfg", "-force")
+	args = append(args, extensionArgs(extension)...)
+	return e.run(ctx, args...)
+}
+
+func (e *DesignerExecutor) Update(ctx context.Context, storage *Storage, extension, version string) error {
+	args := append(e.Infobase.Args(), storage.Args()...)
+	args = append(args, "/ConfigurationRepositoryUpdateCfg", "-v", version, "-force")
+	args = append(args, extensionArgs(extension)...)
+	return e.run(ctx, args...)
+}
+
+func (e *DesignerExecutor) DumpToFiles(ctx context.Context, dumpPath, extension string, update bool) error {
+	args := append(e.Infobase.Args(), "/DumpConfigToFiles", dumpPath)
+	if update {
+		args = append(args, "-update", "-force")
+	}
+	args = append(args, extensionArgs(extension)...)
+	return e.run(ctx, args...)
+}
+
+func (e *DesignerExecutor) run(ctx context.Context, args ...string) error {
+	args = append([]string{"DESIGNER", "/DisableStartupDialogs"}, args...)
+	args = append(args, "/OUT", e.LogFilePath, "/DumpResult", e.DumpResultPath)
+	name := e.V8Files.ThickClient
+	if e.VirtualDisplay {
+		args = append([]string{"-a", name}, args...)
+		name = xvfbRun
+	}
+	_, err, hasError := executeCommand(ctx, name, e.LogFilePath, e.DumpResultPath, args...)
+	if err != nil {
+		return err
+	}
+	if hasError {
+		return errDumpResult
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"storage_to_git/workspace"
+)
+
+// FakeCall records a single operation requested from FakeExecutor.
+type FakeCall struct {
+	Operation string
+	Key       string
+	Version   string
+	Path      string
+}
+
+// FakeExecutor is a scripted Executor that imitates the 1C platform without launching it.
+// It writes canned report files, /DumpResult codes and dump directories, so the whole
+// conversion loop can be exercised on machines without a 1C installation.
+type FakeExecutor struct {
+	LogFilePath string
+	// Reports holds the report text per version key ("cf" or extension name).
+	Reports map[string]string
+	// Results holds queued /DumpResult codes per operation ("report", "unbind", "update", "dump").
+	// An empty queue means success.
+	Results map[string][]int
+	// Dumps holds the files written by DumpToFiles per version key and storage version.
+	// File names are relative to the dump directory.
+	Dumps map[string]map[string]map[string]string
+	Calls []FakeCall
+
+	mu      sync.Mutex
+	current map[string]string
+}
+
+func NewFakeExecutor(logFilePath string) *FakeExecutor {
+	return &FakeExecutor{
+		LogFilePath: logFilePath,
+		Reports:     make(map[string]string),
+		Results:     make(map[string][]int),
+		Dumps:       make(map[string]map[string]map[string]string),
+		current:     make(map[string]string),
+	}
+}
+
+// AddDump registers the files DumpToFiles writes after the key was updated to version.
+func (f *FakeExecutor) AddDump(key, version string, files map[string]string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.Dumps[key] == nil {
+		f.Dumps[key] = make(map[string]map[string]string)
+	}
+	f.Dumps[key][version] = files
+}
+
+func fakeKey(extension string) string {
+	if extension == "" {
+		return "cf"
+	}
+	return extension
+}
+
+func (f *FakeExecutor) Report(ctx context.Context, storage *Storage, extension, reportFilePath string, lastVersion int) error {
+	key := fakeKey(extension)
+	return f.finish(opReport, key, strconv.Itoa(lastVersion), reportFilePath, func() error {
+		report, ok := f.Reports[key]
+		if !ok {
+			return fmt.Errorf("no canned report for %q", key)
+		}
+		return os.WriteFile(reportFilePath, []byte(report), 0644)
+	})
+}
+
+func (f *FakeExecutor) Unbind(ctx context.Context, storage *Storage, extension string) error {
+	key := fakeKey(extension)
+	return f.finish(opUnbind, key, "", storage.Path, func() error {
+		delete(f.current, key)
+		return nil
+	})
+}
+
+func (f *FakeExecutor) Update(ctx context.Context, storage *Storage, extension, version string) error {
+	key := fakeKey(extension)
+	return f.finish(opUpdate, key, version, storage.Path, func() error {
+		f.current[key] = version
+		return nil
+	})
+}
+
+func (f *FakeExecutor) DumpToFiles(ctx context.Context, dumpPath, extension string, update bool) error {
+	key := fakeKey(extension)
+	f.mu.Lock()
+	version := f.current[key]
+	f.mu.Unlock()
+	return f.finish(opDump, key, version, dumpPath, func() error {
+		if version == "" {
+			return fmt.Errorf("%q is not updated to any version", key)
+		}
+		for name, content := range f.Dumps[key][version] {
+			path := filepath.Join(dumpPath, name)
+			if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+				return err
+			}
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				return err
+			}
+		}
+		return os.WriteFile(filepath.Join(dumpPath, "ConfigDumpInfo.xml"), []byte(version), 0644)
+	})
+}
+
+// finish records the call, applies its effect when the scripted result is success,
+// and writes the log and /DumpResult files the way the platform does.
+func (f *FakeExecutor) finish(operation, key, version, path string, apply func() error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.Calls = append(f.Calls, FakeCall{Operation: operation, Key: key, Version: version, Path: path})
+
+	code := 0
+	if queue := f.Results[operation]; len(queue) > 0 {
+		code = queue[0]
+		f.Results[operation] = queue[1:]
+	}
+
+	message := fmt.Sprintf("fake %s %s %s", operation, key, version)
+	if code == 0 {
+		if err := apply(); err != nil {
+			return err
+		}
+	} else {
+		message = fmt.Sprintf("%s failed with code %d", message, code)
+	}
+
+	if err := os.WriteFile(f.LogFilePath, []byte(message), 0644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(workspace.DumpResultPath(f.LogFilePath), []byte(strconv.Itoa(code)), 0644); err != nil {
+		return err
+	}
+	if code != 0 {
+		return errDumpResult
+	}
+	return nil
+}
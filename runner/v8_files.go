@@ -24,4 +24,4 @@ func NewV8Files(catalog1cv8 string) *V8Files {
 		Ibcmd:       filepath.Join(catalog1cv8, "ibcmd"+exeSuffix),
 		Rac:         filepath.Join(catalog1cv8, "rac"+exeSuffix),
 	}
-}
\ No newline at end of file
+}
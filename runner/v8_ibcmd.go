@@ -0,0 +1,128 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	"storage_to_git/models"
+)
+
+// xvfbRun runs a command with a virtual X display.
+const xvfbRun = "xvfb-run"
+
+// IbcmdExecutor binds, updates and exports the service infobase through ibcmd, which runs
+// headless, so Designer is not started for every version. ibcmd has no repository report
+// command: the report is generated by Designer once per storage and run, under xvfb-run on
+// hosts without an X display (see ReportDisplay).
+type IbcmdExecutor struct {
+	V8Files  *V8Files
+	DBPath   string
+	User     *IBUser
+	Designer *DesignerExecutor
+}
+
+func NewIbcmdExecutor(config *models.Config, project *models.Project) (*IbcmdExecutor, error) {
+	designer, err := NewDesignerExecutor(config, project)
+	if err != nil {
+		return nil, err
+	}
+
+	if designer.Infobase.File == "" {
+		return nil, errors.New("ibcmd backend supports only file infobases")
+	}
+	if designer.VirtualDisplay, err = ReportDisplay(); err != nil {
+		return nil, err
+	}
+
+	return &IbcmdExecutor{
+		V8Files:  designer.V8Files,
+		DBPath:   designer.Infobase.File,
+		User:     designer.Infobase.User,
+		Designer: designer,
+	}, nil
+}
+
+func (e *IbcmdExecutor) infobaseArgs(extension string) []string {
+	args := []string{"--db-path=" + e.DBPath}
+	if e.User.Name != "" {
+		args = append(args, "--user="+e.User.Name)
+	}
+	if e.User.Password != "" {
+		args = append(args, "--password="+e.User.Password)
+	}
+	if extension != "" {
+		args = append(args, "--extension="+extension)
+	}
+	return args
+}
+
+func repositoryArgs(storage *Storage) []string {
+	args := []string{"--repository-path=" + storage.Path}
+	if storage.User.Name != "" {
+		args = append(args, "--repository-user="+storage.User.Name)
+	}
+	if storage.User.Password != "" {
+		args = append(args, "--repository-password="+storage.User.Password)
+	}
+	return args
+}
+
+// ReportDisplay checks that Designer can generate storage reports for the ibcmd backend on this
+// host. It reports whether Designer has to run under xvfb-run because there is no X display.
+func ReportDisplay() (virtual bool, err error) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" || os.Getenv("DISPLAY") != "" {
+		return false, nil
+	}
+	if _, err := exec.LookPath(xvfbRun); err != nil {
+		return false, errors.New("storage reports need Designer, but there is no X display (DISPLAY is not set) and xvfb-run is not installed")
+	}
+	return true, nil
+}
+
+// Report generates the report through Designer, because ibcmd has no repository report command.
+func (e *IbcmdExecutor) Report(ctx context.Context, storage *Storage, extension, reportFilePath string, lastVersion int) error {
+	return e.Designer.Report(ctx, storage, extension, reportFilePath, lastVersion)
+}
+
+func (e *IbcmdExecutor) Unbind(ctx context.Context, storage *Storage, extension string) error {
+	args := append([]string{"infobase", "config", "repository", "unbind", "--force"}, e.infobaseArgs(extension)...)
+	return e.run(ctx, args...)
+}
+
+// Update binds the infobase to the repository and updates it to the given version.
+func (e *IbcmdExecutor) Update(ctx context.Context, storage *Storage, extension, version string) error {
+	args := append([]string{"infobase", "config", "repository", "bind", "--force"}, e.infobaseArgs(extension)...)
+	args = append(args, repositoryArgs(storage)...)
+	if err := e.run(ctx, args...); err != nil {
+		return err
+	}
+
+	args = append([]string{"infobase", "config", "repository", "update", "--force", "--version=" + version}, e.infobaseArgs(extension)...)
+	args = append(args, repositoryArgs(storage)...)
+	return e.run(ctx, args...)
+}
+
+func (e *IbcmdExecutor) DumpToFiles(ctx context.Context, dumpPath, extension string, update bool) error {
+	args := append([]string{"infobase", "config", "export", "--sync", "--force"}, e.infobaseArgs(extension)...)
+	if update {
+		args = append(args, "--base="+filepath.Join(dumpPath, "ConfigDumpInfo.xml"))
+	}
+	args = append(args, dumpPath)
+	return e.run(ctx, args...)
+}
+
+func (e *IbcmdExecutor) run(ctx context.Context, args ...string) error {
+	logger := models.FromContext(ctx)
+
+	output, err := runProcess(ctx, e.V8Files.Ibcmd, args...)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Command executed successfully", "output", string(output))
+	return nil
+}
@@ -0,0 +1,68 @@
+package runner
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"storage_to_git/models"
+)
+
+func TestIbcmdReportRunsDesignerWithoutDisplay(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		t.Skip("Designer needs no X display here")
+	}
+	bin := t.TempDir()
+	calls := filepath.Join(bin, "calls")
+	script := "#!/bin/sh\necho \"$*\" > " + calls + "\n"
+	if err := os.WriteFile(filepath.Join(bin, xvfbRun), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	data := t.TempDir()
+	config := &models.Config{Catalog1cv8: "/opt/1cv8"}
+	project := &models.Project{
+		Backend:         models.BackendIbcmd,
+		InfoBase:        models.InfoBase{InfoBaseFile: filepath.Join(data, "ib")},
+		ProjectDataPath: data,
+		V8LogFilePath:   "log.txt",
+	}
+
+	t.Setenv("DISPLAY", ":1")
+	t.Setenv("PATH", bin)
+	executor, err := NewIbcmdExecutor(config, project)
+	if err != nil {
+		t.Fatalf("NewIbcmdExecutor with a display: %v", err)
+	}
+	if executor.Designer.VirtualDisplay {
+		t.Error("xvfb-run used although DISPLAY is set")
+	}
+
+	t.Setenv("DISPLAY", "")
+	executor, err = NewIbcmdExecutor(config, project)
+	if err != nil {
+		t.Fatalf("NewIbcmdExecutor with xvfb-run: %v", err)
+	}
+	ctx := models.WithLogger(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	storage := &Storage{Path: "/stor/cf", User: &StorageUser{Name: "reader"}}
+	if err := executor.Report(ctx, storage, "", filepath.Join(data, "cf.report"), 0); err != nil {
+		t.Fatalf("Report: %v", err)
+	}
+	args, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "-a " + executor.V8Files.ThickClient + " DESIGNER "; !strings.HasPrefix(string(args), want) {
+		t.Errorf("xvfb-run called with %q, want prefix %q", args, want)
+	}
+
+	t.Setenv("PATH", t.TempDir())
+	if _, err := NewIbcmdExecutor(config, project); err == nil {
+		t.Error("NewIbcmdExecutor succeeded without a display and without xvfb-run")
+	}
+}
@@ -1,12 +1,126 @@
 package runner
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"storage_to_git/models"
+)
 
 type Infobase struct {
-	Path           string
+	File           string
+	Server         string
+	Ref            string
+	PermissionCode string
 	User           *IBUser
 }
 
+// NewInfobase builds the infobase from structured settings or, when they are empty,
+// from the legacy infobase_path connection string, and validates the result.
+func NewInfobase(config models.InfoBase) (*Infobase, error) {
+	ib := &Infobase{
+		File:   config.InfoBaseFile,
+		Server: config.InfoBaseServer,
+		Ref:    config.InfoBaseRef,
+		User: &IBUser{
+			Name:     config.InfoBaseUser,
+			Password: config.InfoBasePassword,
+		},
+	}
+
+	if config.InfoBasePath != "" {
+		if ib.File != "" || ib.Server != "" || ib.Ref != "" {
+			return nil, errors.New("infobase_path cannot be combined with infobase_file, infobase_server or infobase_ref")
+		}
+		params, err := parseConnectionString(config.InfoBasePath)
+		if err != nil {
+			return nil, fmt.Errorf("invalid infobase_path: %w", err)
+		}
+		ib.File = params["file"]
+		ib.Server = params["srvr"]
+		ib.Ref = params["ref"]
+	}
+
+	if err := ib.Validate(); err != nil {
+		return nil, err
+	}
+	return ib, nil
+}
+
+func (ib *Infobase) Validate() error {
+	switch {
+	case ib.File != "" && (ib.Server != "" || ib.Ref != ""):
+		return errors.New("infobase must be either file or client-server, not both")
+	case ib.File != "":
+		return nil
+	case ib.Server == "" && ib.Ref == "":
+		return errors.New("infobase file path or server and ref must be specified")
+	case ib.Server == "":
+		return errors.New("infobase server must be specified for client-server infobase")
+	case ib.Ref == "":
+		return errors.New("infobase ref must be specified for client-server infobase")
+	}
+	return nil
+}
+
+// ConnectionString renders the infobase as a 1C connection string with quoted values.
 func (ib *Infobase) ConnectionString() string {
-	return fmt.Sprintf("/IBConnectionString %s %s", ib.Path, ib.User.ConnectionString())
+	if ib.File != "" {
+		return fmt.Sprintf("File=%s;", quoteConnectionValue(ib.File))
+	}
+	return fmt.Sprintf("Srvr=%s;Ref=%s;", quoteConnectionValue(ib.Server), quoteConnectionValue(ib.Ref))
+}
+
+func (ib *Infobase) Args() []string {
+	args := append([]string{"/IBConnectionString", ib.ConnectionString()}, ib.User.Args()...)
+	if ib.PermissionCode != "" {
+		args = append(args, "/UC", ib.PermissionCode)
+	}
+	return args
+}
+
+func quoteConnectionValue(value string) string {
+	return `"` + strings.ReplaceAll(value, `"`, `""`) + `"`
+}
+
+// parseConnectionString splits a 1C connection string into lower-cased keys and unquoted values.
+func parseConnectionString(s string) (map[string]string, error) {
+	params := make(map[string]string)
+
+	for rest := strings.TrimSpace(s); rest != ""; rest = strings.TrimSpace(rest) {
+		key, value, found := strings.Cut(rest, "=")
+		if !found {
+			return nil, fmt.Errorf("missing '=' in %q", rest)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimLeft(value, " ")
+
+		if strings.HasPrefix(value, `"`) {
+			var b strings.Builder
+			i := 1
+			for ; i < len(value); i++ {
+				if value[i] == '"' {
+					if i+1 < len(value) && value[i+1] == '"' {
+						b.WriteByte('"')
+						i++
+						continue
+					}
+					break
+				}
+				b.WriteByte(value[i])
+			}
+			if i >= len(value) {
+				return nil, fmt.Errorf("unterminated quote in value of %q", key)
+			}
+			params[key] = b.String()
+			rest = strings.TrimSpace(value[i+1:])
+			rest = strings.TrimPrefix(rest, ";")
+		} else {
+			value, rest, _ = strings.Cut(value, ";")
+			params[key] = strings.TrimSpace(value)
+		}
+	}
+
+	return params, nil
 }
@@ -0,0 +1,90 @@
+package runner
+
+import (
+	"reflect"
+	"testing"
+
+	"storage_to_git/models"
+)
+
+func TestParseConnectionString(t *testing.T) {
+	tests := []struct {
+		in   string
+		want map[string]string
+	}{
+		{`File="C:\Bases\ERP";`, map[string]string{"file": `C:\Bases\ERP`}},
+		{`File=/srv/erp`, map[string]string{"file": "/srv/erp"}},
+		{`Srvr="srv1c:1541";Ref="erp";`, map[string]string{"srvr": "srv1c:1541", "ref": "erp"}},
+		{` srvr = srv1c ; REF = erp ; `, map[string]string{"srvr": "srv1c", "ref": "erp"}},
+		{`File="/srv/a ""quoted"" base";`, map[string]string{"file": `/srv/a "quoted" base`}},
+		{`File="/srv/a;b";Usr=admin;`, map[string]string{"file": "/srv/a;b", "usr": "admin"}},
+		{`Srvr="srv";Ref="""";`, map[string]string{"srvr": "srv", "ref": `"`}},
+	}
+	for _, tt := range tests {
+		got, err := parseConnectionString(tt.in)
+		if err != nil {
+			t.Errorf("parseConnectionString(%q): %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseConnectionString(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{`File`, `File="/srv/erp`, `Srvr=srv;Ref`} {
+		if _, err := parseConnectionString(in); err == nil {
+			t.Errorf("parseConnectionString(%q) succeeded, want an error", in)
+		}
+	}
+}
+
+func TestInfobaseConnectionStringRoundTrip(t *testing.T) {
+	for _, ib := range []Infobase{
+		{File: "/srv/erp"},
+		{File: `C:\Bases\"Main"; copy`},
+		{Server: "srv1c:1541", Ref: "erp"},
+		{Server: `srv;1c`, Ref: `erp "prod"`},
+	} {
+		connection := ib.ConnectionString()
+		got, err := NewInfobase(models.InfoBase{InfoBasePath: connection})
+		if err != nil {
+			t.Errorf("NewInfobase(%q): %v", connection, err)
+			continue
+		}
+		if got.File != ib.File || got.Server != ib.Server || got.Ref != ib.Ref {
+			t.Errorf("%q read back as file %q, server %q, ref %q", connection, got.File, got.Server, got.Ref)
+		}
+		if again := got.ConnectionString(); again != connection {
+			t.Errorf("ConnectionString() = %q after a round trip, want %q", again, connection)
+		}
+	}
+}
+
+func TestNewInfobaseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config models.InfoBase
+	}{
+		{"nothing", models.InfoBase{}},
+		{"server without ref", models.InfoBase{InfoBaseServer: "srv1c"}},
+		{"ref without server", models.InfoBase{InfoBaseRef: "erp"}},
+		{"file and server fields", models.InfoBase{InfoBaseFile: "/srv/erp", InfoBaseServer: "srv1c", InfoBaseRef: "erp"}},
+		{"file and ref fields", models.InfoBase{InfoBaseFile: "/srv/erp", InfoBaseRef: "erp"}},
+		{"file and server in the path", models.InfoBase{InfoBasePath: `File="/srv/erp";Srvr="srv1c";Ref="erp";`}},
+		{"path and fields", models.InfoBase{InfoBasePath: `File="/srv/erp";`, InfoBaseServer: "srv1c"}},
+		{"broken path", models.InfoBase{InfoBasePath: `File="/srv/erp`}},
+	}
+	for _, tt := range tests {
+		if _, err := NewInfobase(tt.config); err == nil {
+			t.Errorf("%s: NewInfobase succeeded, want an error", tt.name)
+		}
+	}
+
+	ib, err := NewInfobase(models.InfoBase{InfoBaseServer: "srv1c", InfoBaseRef: "erp", InfoBaseUser: "admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ib.ConnectionString() != `Srvr="srv1c";Ref="erp";` || ib.User.Name != "admin" {
+		t.Errorf("NewInfobase from fields = %+v", ib)
+	}
+}
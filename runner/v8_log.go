@@ -5,7 +5,6 @@ import (
 	"bytes"
 	"log/slog"
 	"os"
-	"path/filepath"
 	"strings"
 )
 
@@ -29,12 +28,7 @@ func Log1C(logger *slog.Logger, v8LogFile string) {
 	}
 }
 
-func getDumpFilePath(logFilePath string) string {
-	return logFilePath[:len(logFilePath)-len(filepath.Ext(logFilePath))] + ".dump"
-}
-
-func CheckForErrors(logger *slog.Logger, logFilePath string) bool {
-	errorFilePath := getDumpFilePath(logFilePath)
+func CheckForErrors(logger *slog.Logger, errorFilePath string) bool {
 	if _, err := os.Stat(errorFilePath); os.IsNotExist(err) {
 		return false
 	}
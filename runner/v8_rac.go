@@ -0,0 +1,306 @@
+package runner
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"storage_to_git/models"
+)
+
+const (
+	defaultRasPort     = "1545"
+	defaultClusterPort = "1541"
+)
+
+// Rac administers the 1C server cluster hosting a client-server service infobase.
+type Rac struct {
+	Path            string
+	Address         string
+	ClusterUser     string
+	ClusterPassword string
+	Infobase        *Infobase
+	Timeouts        operationTimeouts
+}
+
+func NewRac(config *models.Config, project *models.Project) (*Rac, error) {
+	admin := project.ClusterAdmin
+
+	v8path := config.Catalog1cv8
+	if project.Catalog1cv8 != "" {
+		v8path = project.Catalog1cv8
+	}
+
+	infobase, err := NewInfobase(project.InfoBase)
+	if err != nil {
+		return nil, err
+	}
+	timeouts, err := parseTimeouts(project.Timeouts)
+	if err != nil {
+		return nil, err
+	}
+	if infobase.Server == "" {
+		return nil, errors.New("cluster administration requires a client-server infobase")
+	}
+	if admin.LockSessions && admin.PermissionCode == "" {
+		return nil, errors.New("permission_code is required to lock sessions")
+	}
+
+	address := admin.RasAddress
+	if address == "" {
+		host := infobase.Server
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		address = net.JoinHostPort(host, defaultRasPort)
+	}
+
+	return &Rac{
+		Path:            NewV8Files(v8path).Rac,
+		Address:         address,
+		ClusterUser:     admin.ClusterUser,
+		ClusterPassword: admin.ClusterPassword,
+		Infobase:        infobase,
+		Timeouts:        timeouts,
+	}, nil
+}
+
+// LockInfobase denies new sessions and terminates existing ones according to the project settings.
+// The returned function restores the session lock the infobase had before and must be called
+// even if the run fails.
+func LockInfobase(ctx context.Context, config *models.Config, project *models.Project) (func(), error) {
+	rac, err := NewRac(config, project)
+	if err != nil {
+		return nil, err
+	}
+	return rac.lockInfobase(ctx, project.ClusterAdmin)
+}
+
+func (r *Rac) lockInfobase(ctx context.Context, admin *models.ClusterAdmin) (func(), error) {
+	logger := models.FromContext(ctx)
+
+	clusterID, infobaseID, err := r.findInfobase(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	release := func() {}
+	if admin.LockSessions {
+		// An administrator may have locked the infobase already, that lock is put back on release.
+		previous, err := r.sessionsDeny(ctx, clusterID, infobaseID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read session lock: %w", err)
+		}
+
+		logger.Info("Locking sessions on service infobase", "ras", r.Address, "infobase", r.Infobase.Ref)
+		lock := sessionLock{Deny: true, Message: admin.LockMessage, PermissionCode: admin.PermissionCode}
+		if err := r.setSessionsDeny(ctx, clusterID, infobaseID, lock); err != nil {
+			return nil, fmt.Errorf("failed to lock sessions: %w", err)
+		}
+		release = func() {
+			logger.Info("Restoring session lock on service infobase", "infobase", r.Infobase.Ref, "sessions_deny", previous.Deny)
+			// The lock must be lifted even when the run was cancelled.
+			if err := r.setSessionsDeny(context.WithoutCancel(ctx), clusterID, infobaseID, previous); err != nil {
+				logger.Error("Failed to release session lock", "error", err)
+			}
+		}
+	}
+
+	if admin.TerminateSessions {
+		if err := r.terminateSessions(ctx, clusterID, infobaseID); err != nil {
+			release()
+			return nil, fmt.Errorf("failed to terminate sessions: %w", err)
+		}
+	}
+
+	return release, nil
+}
+
+func (r *Rac) clusterArgs(clusterID string) []string {
+	args := []string{"--cluster=" + clusterID}
+	if r.ClusterUser != "" {
+		args = append(args, "--cluster-user="+r.ClusterUser)
+	}
+	if r.ClusterPassword != "" {
+		args = append(args, "--cluster-pwd="+r.ClusterPassword)
+	}
+	return args
+}
+
+// findInfobase returns the cluster hosting the service infobase and the id of the infobase in it.
+// If several clusters of the server have an infobase of that name, the cluster is chosen by the
+// port of the infobase server.
+func (r *Rac) findInfobase(ctx context.Context) (clusterID, infobaseID string, err error) {
+	clusters, err := r.run(ctx, "cluster", "list")
+	if err != nil {
+		return "", "", err
+	}
+
+	type candidate struct {
+		cluster, port, infobase string
+	}
+	var candidates []candidate
+	var listErr error
+	for _, cluster := range clusters {
+		if cluster["cluster"] == "" {
+			continue
+		}
+		args := append([]string{"infobase", "summary", "list"}, r.clusterArgs(cluster["cluster"])...)
+		records, err := r.run(ctx, args...)
+		if err != nil {
+			// The cluster may have other administrators, the infobase may be in another one.
+			listErr = errors.Join(listErr, fmt.Errorf("cluster %s: %w", cluster["cluster"], err))
+			continue
+		}
+		for _, record := range records {
+			if strings.EqualFold(record["name"], r.Infobase.Ref) {
+				candidates = append(candidates, candidate{cluster["cluster"], cluster["port"], record["infobase"]})
+				break
+			}
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		if listErr != nil {
+			return "", "", fmt.Errorf("infobase %q not found at %s: %w", r.Infobase.Ref, r.Address, listErr)
+		}
+		return "", "", fmt.Errorf("infobase %q not found in any cluster at %s", r.Infobase.Ref, r.Address)
+	case 1:
+		return candidates[0].cluster, candidates[0].infobase, nil
+	}
+
+	port := defaultClusterPort
+	if _, p, err := net.SplitHostPort(r.Infobase.Server); err == nil {
+		port = p
+	}
+	for _, c := range candidates {
+		if c.port == port {
+			return c.cluster, c.infobase, nil
+		}
+	}
+	return "", "", fmt.Errorf("infobase %q found in %d clusters at %s, none of them listens on port %s of %s", r.Infobase.Ref, len(candidates), r.Address, port, r.Infobase.Server)
+}
+
+func (r *Rac) terminateSessions(ctx context.Context, clusterID, infobaseID string) error {
+	logger := models.FromContext(ctx)
+
+	args := append([]string{"session", "list", "--infobase=" + infobaseID}, r.clusterArgs(clusterID)...)
+	records, err := r.run(ctx, args...)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		sessionID := record["session"]
+		if sessionID == "" {
+			continue
+		}
+		logger.Info("Terminating session", "session", sessionID, "user", record["user-name"], "app", record["app-id"], "host", record["host"])
+		args := append([]string{"session", "terminate", "--session=" + sessionID}, r.clusterArgs(clusterID)...)
+		if _, err := r.run(ctx, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sessionLock is the session lock of an infobase as rac infobase info reports it.
+type sessionLock struct {
+	Deny           bool
+	Message        string
+	PermissionCode string
+	// DeniedFrom and DeniedTo limit the lock to a period; empty means unlimited.
+	DeniedFrom string
+	DeniedTo   string
+}
+
+func (r *Rac) infobaseUserArgs() []string {
+	var args []string
+	if r.Infobase.User.Name != "" {
+		args = append(args, "--infobase-user="+r.Infobase.User.Name)
+	}
+	if r.Infobase.User.Password != "" {
+		args = append(args, "--infobase-pwd="+r.Infobase.User.Password)
+	}
+	return args
+}
+
+func (r *Rac) sessionsDeny(ctx context.Context, clusterID, infobaseID string) (sessionLock, error) {
+	args := append([]string{"infobase", "info", "--infobase=" + infobaseID}, r.clusterArgs(clusterID)...)
+	records, err := r.run(ctx, append(args, r.infobaseUserArgs()...)...)
+	if err != nil {
+		return sessionLock{}, err
+	}
+	if len(records) == 0 {
+		return sessionLock{}, fmt.Errorf("no information about infobase %s", infobaseID)
+	}
+	info := records[0]
+	return sessionLock{
+		Deny:           info["sessions-deny"] == "on",
+		Message:        info["denied-message"],
+		PermissionCode: info["permission-code"],
+		DeniedFrom:     info["denied-from"],
+		DeniedTo:       info["denied-to"],
+	}, nil
+}
+
+func (r *Rac) setSessionsDeny(ctx context.Context, clusterID, infobaseID string, lock sessionLock) error {
+	args := append([]string{"infobase", "update", "--infobase=" + infobaseID}, r.clusterArgs(clusterID)...)
+	args = append(args, r.infobaseUserArgs()...)
+	if lock.Deny {
+		args = append(args, "--sessions-deny=on")
+	} else {
+		args = append(args, "--sessions-deny=off")
+	}
+	// Empty values are passed too: rac keeps omitted ones, so a lock window set by an
+	// administrator would delay our lock or outlive the restored one.
+	args = append(args,
+		"--denied-message="+lock.Message,
+		"--permission-code="+lock.PermissionCode,
+		"--denied-from="+lock.DeniedFrom,
+		"--denied-to="+lock.DeniedTo,
+	)
+	_, err := r.run(ctx, args...)
+	return err
+}
+
+func (r *Rac) run(ctx context.Context, args ...string) ([]map[string]string, error) {
+	ctx, cancel := r.Timeouts.withTimeout(ctx, opRac)
+	defer cancel()
+
+	output, err := runProcess(ctx, r.Path, append(args, r.Address)...)
+	if err != nil {
+		return nil, err
+	}
+	return parseRacOutput(string(output)), nil
+}
+
+// parseRacOutput splits rac output into records of "key : value" lines separated by blank lines.
+func parseRacOutput(output string) []map[string]string {
+	var records []map[string]string
+	var current map[string]string
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			current = nil
+			continue
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		if current == nil {
+			current = make(map[string]string)
+			records = append(records, current)
+		}
+		current[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+
+	return records
+}
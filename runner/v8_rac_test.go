@@ -0,0 +1,112 @@
+package runner
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"storage_to_git/models"
+)
+
+// fakeRac answers like rac for a server with two clusters; the service infobase "erp" exists
+// in both, and the one on port 1641 is locked by an administrator. Every call is appended to
+// the calls file.
+const fakeRac = `#!/bin/sh
+echo "$*" >> "$(dirname "$0")/calls"
+case "$1 $2" in
+"cluster list")
+	printf 'cluster : c1\nhost : srv\nport : 1541\n\ncluster : c2\nhost : srv\nport : 1641\n'
+	;;
+"infobase summary")
+	case "$*" in
+	*--cluster=c1*) printf 'infobase : ib-other\nname : other\n\ninfobase : ib-1\nname : erp\n' ;;
+	*--cluster=c2*) printf 'infobase : ib-2\nname : ERP\n' ;;
+	esac
+	;;
+"infobase info")
+	printf 'infobase : ib-2\nname : ERP\nsessions-deny : on\ndenied-message : "Maintenance"\npermission-code : "admin"\ndenied-from : 2024-01-01T10:00:00\ndenied-to :\n'
+	;;
+esac
+`
+
+func TestLockInfobaseRestoresPreviousLock(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake rac is a shell script")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rac")
+	if err := os.WriteFile(path, []byte(fakeRac), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	rac := &Rac{
+		Path:     path,
+		Address:  "srv:1545",
+		Infobase: &Infobase{Server: "srv:1641", Ref: "erp", User: &IBUser{}},
+	}
+	ctx := models.WithLogger(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	release, err := rac.lockInfobase(ctx, &models.ClusterAdmin{LockSessions: true, LockMessage: "Conversion", PermissionCode: "s2g"})
+	if err != nil {
+		t.Fatalf("lockInfobase: %v", err)
+	}
+	release()
+
+	data, err := os.ReadFile(filepath.Join(dir, "calls"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var updates []string
+	for _, call := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if strings.HasPrefix(call, "infobase update") {
+			updates = append(updates, call)
+		}
+	}
+	want := []string{
+		"infobase update --infobase=ib-2 --cluster=c2 --sessions-deny=on --denied-message=Conversion --permission-code=s2g --denied-from= --denied-to= srv:1545",
+		"infobase update --infobase=ib-2 --cluster=c2 --sessions-deny=on --denied-message=Maintenance --permission-code=admin --denied-from=2024-01-01T10:00:00 --denied-to= srv:1545",
+	}
+	if strings.Join(updates, "\n") != strings.Join(want, "\n") {
+		t.Errorf("updates:\n%s\nwant:\n%s", strings.Join(updates, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestFindInfobase(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake rac is a shell script")
+	}
+	path := filepath.Join(t.TempDir(), "rac")
+	if err := os.WriteFile(path, []byte(fakeRac), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	ctx := models.WithLogger(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	tests := []struct {
+		server, ref     string
+		cluster, infoID string
+	}{
+		{"srv", "erp", "c1", "ib-1"},
+		{"srv:1541", "erp", "c1", "ib-1"},
+		{"srv:1641", "erp", "c2", "ib-2"},
+		{"srv:1641", "other", "c1", "ib-other"},
+		{"srv:1741", "erp", "", ""},
+		{"srv", "missing", "", ""},
+	}
+	for _, tt := range tests {
+		rac := &Rac{Path: path, Address: "srv:1545", Infobase: &Infobase{Server: tt.server, Ref: tt.ref, User: &IBUser{}}}
+		cluster, infobase, err := rac.findInfobase(ctx)
+		if tt.cluster == "" {
+			if err == nil {
+				t.Errorf("findInfobase(%s, %s) = %s, %s; want an error", tt.server, tt.ref, cluster, infobase)
+			}
+			continue
+		}
+		if err != nil || cluster != tt.cluster || infobase != tt.infoID {
+			t.Errorf("findInfobase(%s, %s) = %s, %s, %v; want %s, %s", tt.server, tt.ref, cluster, infobase, err, tt.cluster, tt.infoID)
+		}
+	}
+}
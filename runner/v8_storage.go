@@ -1,12 +1,10 @@
 package runner
 
-import "fmt"
-
 type Storage struct {
 	Path string
 	User *StorageUser
 }
 
-func (s *Storage) ConnectionString() string {
-	return fmt.Sprintf("/ConfigurationRepositoryF %s %s", s.Path, s.User.ConnectionString())
+func (s *Storage) Args() []string {
+	return append([]string{"/ConfigurationRepositoryF", s.Path}, s.User.Args()...)
 }
@@ -2,7 +2,6 @@ package runner
 
 import (
 	"bufio"
-	"encoding/json"
 	"fmt"
 	"log/slog"
 	"os"
@@ -14,22 +13,18 @@ import (
 	"time"
 
 	"storage_to_git/models"
+	"storage_to_git/workspace"
 )
 
-func processReports(logger *slog.Logger, projectPath string, storageUsers []models.UserMapping, project *models.Project) ([]*models.Report, error) {
+// processReports parses the given report files. Missing files are skipped with a warning.
+func processReports(logger *slog.Logger, files []string, storageUsers []models.UserMapping, project *models.Project) ([]*models.Report, error) {
 	var reports []*models.Report
 
-	files, err := filepath.Glob(filepath.Join(projectPath, "*.report"))
-	if err != nil {
-		return nil, fmt.Errorf("error finding report files: %v", err)
-	}
-
-	if len(files) == 0 {
-		logger.Warn("Report files not found", "path", projectPath)
-		return reports, nil
-	}
-
 	for _, file := range files {
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			logger.Warn("Report file not found", "path", file)
+			continue
+		}
 		report, err := parseReportFile(logger, file, storageUsers, project)
 		if err != nil {
 			logger.Error("Error parsing report", "file", file, "error", err)
@@ -53,7 +48,7 @@ func parseReportFile(logger *slog.Logger, filePath string, storageUsers []models
 	}
 	scanner := bufio.NewScanner(file)
 	var currentVersion *models.ReportVersion
-	var inComment bool
+	var inComment, inLabelComment bool
 
 	// First line is special for storage path
 	if scanner.Scan() {
@@ -75,6 +70,8 @@ func parseReportFile(logger *slog.Logger, filePath string, storageUsers []models
 		if trimmedLine == "" {
 			if inComment && currentVersion != nil {
 				currentVersion.Comment += "\n" // Preserve empty lines in comments
+			} else if inLabelComment && currentVersion != nil {
+				currentVersion.LabelComment += "\n"
 			}
 			continue
 		}
@@ -86,6 +83,7 @@ func parseReportFile(logger *slog.Logger, filePath string, storageUsers []models
 			strings.HasPrefix(trimmedLine, "Изменены") ||
 			(strings.HasPrefix(trimmedLine, "Версия:") && !strings.Contains(trimmedLine, "Версия конфигурации")) {
 			inComment = false
+			inLabelComment = false
 		}
 
 		if strings.HasPrefix(trimmedLine, "Дата отчета:") {
@@ -125,7 +123,8 @@ func parseReportFile(logger *slog.Logger, filePath string, storageUsers []models
 			if strings.HasPrefix(trimmedLine, "Версия конфигурации:") {
 				currentVersion.ConfigVersion = strings.TrimSpace(strings.SplitN(trimmedLine, ":", 2)[1])
 			} else if strings.HasPrefix(trimmedLine, "Пользователь:") {
-				currentVersion.User = findUserMapping(storageUsers, strings.TrimSpace(strings.SplitN(trimmedLine, ":", 2)[1]))
+				currentVersion.StorageUser = strings.TrimSpace(strings.SplitN(trimmedLine, ":", 2)[1])
+				currentVersion.User = findUserMapping(storageUsers, currentVersion.StorageUser)
 			} else if strings.HasPrefix(trimmedLine, "Дата создания:") {
 				dateStr := strings.TrimSpace(strings.SplitN(trimmedLine, ":", 2)[1])
 				currentVersion.CreationDate, err = parseDate(dateStr)
@@ -141,10 +140,12 @@ func parseReportFile(logger *slog.Logger, filePath string, storageUsers []models
 			} else if strings.HasPrefix(trimmedLine, "Комментарий:") {
 				currentVersion.Comment = strings.TrimSpace(strings.SplitN(trimmedLine, ":", 2)[1])
 				inComment = true
+				inLabelComment = false
 			} else if strings.HasPrefix(trimmedLine, "Метка:") {
 				currentVersion.Label = strings.TrimSpace(strings.SplitN(trimmedLine, ":", 2)[1])
 			} else if strings.HasPrefix(trimmedLine, "Комментарий метки:") {
-				// Ignore
+				currentVersion.LabelComment = strings.TrimSpace(strings.SplitN(trimmedLine, ":", 2)[1])
+				inLabelComment = true
 			} else if strings.Contains(trimmedLine, "Добавлены") {
 				parts := strings.Fields(trimmedLine)
 				if len(parts) >= 2 {
@@ -162,6 +163,8 @@ func parseReportFile(logger *slog.Logger, filePath string, storageUsers []models
 			} else if inComment {
 				// This is a continuation of a multiline comment
 				currentVersion.Comment += "\n" + line
+			} else if inLabelComment {
+				currentVersion.LabelComment += "\n" + line
 			}
 		}
 	}
@@ -203,30 +206,13 @@ func sortVersionsByCreation(versions []models.ReportVersion) {
 	})
 }
 
-func saveVersionsConfig(projectPath string, config models.VersionMap) error {
-	file, err := os.Create(projectPath)
-	if err != nil {
-		return fmt.Errorf("error creating file: %v", err)
-	}
-	defer file.Close()
-
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	err = encoder.Encode(config)
-	if err != nil {
-		return fmt.Errorf("error encoding JSON: %v", err)
-	}
-
-	return nil
-}
-
 func updateVersionsConfig(logger *slog.Logger, config models.VersionMap, version models.ReportVersion) models.VersionMap {
 	updatedConfig := make(models.VersionMap)
 	for k, v := range config {
 		updatedConfig[k] = v
 	}
 
-	fileKey := getFileKey(version.FileName)
+	fileKey := workspace.ReportKey(version.FileName)
 	versionNum, err := strconv.Atoi(version.Version)
 	if err != nil {
 		logger.Error("error parsing version", "version", version.Version, "error", err)
@@ -242,7 +228,7 @@ func filterVersionsByConfig(logger *slog.Logger, versions []models.ReportVersion
 	var filtered []models.ReportVersion
 
 	for _, version := range versions {
-		fileKey := getFileKey(version.FileName)
+		fileKey := workspace.ReportKey(version.FileName)
 		lastVersion, exists := config[fileKey]
 		versionNum, err := strconv.Atoi(version.Version)
 		if err != nil {
@@ -258,33 +244,6 @@ func filterVersionsByConfig(logger *slog.Logger, versions []models.ReportVersion
 	return filtered
 }
 
-func getFileKey(fileName string) string {
-	baseName := filepath.Base(fileName)
-	return strings.TrimSuffix(baseName, ".report")
-}
-
-func readVersionsConfig(projectPath string) (models.VersionMap, error) {
-	config := make(models.VersionMap)
-
-	if _, err := os.Stat(projectPath); os.IsNotExist(err) {
-		return config, nil
-	}
-
-	file, err := os.Open(projectPath)
-	if err != nil {
-		return nil, fmt.Errorf("error opening file: %v", err)
-	}
-	defer file.Close()
-
-	decoder := json.NewDecoder(file)
-	err = decoder.Decode(&config)
-	if err != nil {
-		return nil, fmt.Errorf("error decoding JSON: %v", err)
-	}
-
-	return config, nil
-}
-
 func findUserMapping(storageUsers []models.UserMapping, user string) models.UserMapping {
 	for _, mapping := range storageUsers {
 		if mapping.StorageUser == user {
@@ -338,4 +297,4 @@ func ComparePaths(logger *slog.Logger, path1, path2 string) bool {
 	logger.Debug("compare paths", "path1_cleaned", clean1, "path2_cleaned", clean2, "result", clean1 == clean2)
 
 	return clean1 == clean2
-}
\ No newline at end of file
+}
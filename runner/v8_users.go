@@ -1,9 +1,7 @@
 package runner
 
-import "fmt"
-
 type User interface {
-	ConnectionString() string
+	Args() []string
 }
 
 type IBUser struct {
@@ -11,14 +9,14 @@ type IBUser struct {
 	Password string
 }
 
-func (u *IBUser) ConnectionString() string {
+func (u *IBUser) Args() []string {
 	if u.Name == "" {
-		return ""
+		return nil
 	}
 	if u.Password == "" {
-		return fmt.Sprintf("/N %s", u.Name)
+		return []string{"/N", u.Name}
 	}
-	return fmt.Sprintf("/N %s /P %s", u.Name, u.Password)
+	return []string{"/N", u.Name, "/P", u.Password}
 }
 
 type StorageUser struct {
@@ -26,9 +24,9 @@ type StorageUser struct {
 	Password string
 }
 
-func (u *StorageUser) ConnectionString() string {
+func (u *StorageUser) Args() []string {
 	if u.Password == "" {
-		return fmt.Sprintf("/ConfigurationRepositoryN %s", u.Name)
+		return []string{"/ConfigurationRepositoryN", u.Name}
 	}
-	return fmt.Sprintf("/ConfigurationRepositoryN %s /ConfigurationRepositoryP %s", u.Name, u.Password)
+	return []string{"/ConfigurationRepositoryN", u.Name, "/ConfigurationRepositoryP", u.Password}
 }
@@ -0,0 +1,78 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronMatches(t *testing.T) {
+	// 2024-03-01 is a Friday.
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 3, day, hour, minute, 0, 0, time.Local)
+	}
+	tests := []struct {
+		spec string
+		at   time.Time
+		want bool
+	}{
+		{"*/15 * * * *", at(1, 10, 0), true},
+		{"*/15 * * * *", at(1, 10, 45), true},
+		{"*/15 * * * *", at(1, 10, 7), false},
+		{"5,10,50-52 0 * * *", at(1, 0, 10), true},
+		{"5,10,50-52 0 * * *", at(1, 0, 51), true},
+		{"5,10,50-52 0 * * *", at(1, 0, 53), false},
+		{"5,10,50-52 0 * * *", at(1, 1, 10), false},
+		{"0 9-17/4 * * *", at(1, 13, 0), true},
+		{"0 9-17/4 * * *", at(1, 17, 0), true},
+		{"0 9-17/4 * * *", at(1, 11, 0), false},
+		{"30 5/6 * * *", at(1, 23, 30), true},
+		{"30 5/6 * * *", at(1, 0, 30), false},
+		{"0 12 * * 1-5", at(1, 12, 0), true},  // Friday
+		{"0 12 * * 1-5", at(2, 12, 0), false}, // Saturday
+		{"0 12 * * 0", at(3, 12, 0), true},    // Sunday as 0
+		{"0 12 * * 7", at(3, 12, 0), true},    // Sunday as 7
+		{"0 12 * * 7", at(4, 12, 0), false},
+		{"0 0 1 * *", at(1, 0, 0), true},
+		{"0 0 1 * *", at(2, 0, 0), false},
+		{"0 0 * 4 *", at(1, 0, 0), false},
+		// With both day fields restricted either of them matches, as in cron.
+		{"0 0 13 * 5", at(1, 0, 0), true},   // a Friday
+		{"0 0 13 * 5", at(13, 0, 0), true},  // the 13th, a Wednesday
+		{"0 0 13 * 5", at(14, 0, 0), false}, // neither
+		// With one day field restricted, it alone decides.
+		{"0 0 13 * *", at(8, 0, 0), false}, // a Friday
+		{"0 0 * * 5", at(13, 0, 0), false}, // the 13th
+	}
+	for _, tt := range tests {
+		cron, err := parseCron(tt.spec)
+		if err != nil {
+			t.Errorf("parseCron(%q): %v", tt.spec, err)
+			continue
+		}
+		if got := cron.matches(tt.at); got != tt.want {
+			t.Errorf("%q matches %s = %v, want %v", tt.spec, tt.at.Format("Mon 2006-01-02 15:04"), got, tt.want)
+		}
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, spec := range []string{
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"a * * * *",
+		"1-b * * * *",
+		"1,,2 * * * *",
+	} {
+		if _, err := parseCron(spec); err == nil {
+			t.Errorf("parseCron(%q) succeeded, want an error", spec)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"storage_to_git/models"
+)
+
+const (
+	clockLayout    = "15:04"
+	blackoutLayout = "2006-01-02 15:04"
+	// searchLimit bounds the search for the next allowed minute.
+	searchLimit = 5 * 366 * 24 * time.Hour
+)
+
+// Schedule plans project runs from an interval or cron expression, limited to allowed
+// daily windows, excluding blackout periods, with optional random jitter.
+type Schedule struct {
+	interval  time.Duration
+	cron      *cronExpr
+	windows   []window
+	blackouts []period
+	jitter    time.Duration
+}
+
+// window is a daily time range in minutes since midnight, including the start and excluding
+// the end. It may wrap past midnight.
+type window struct {
+	from, to int
+}
+
+type period struct {
+	from, to time.Time
+}
+
+// New parses the schedule settings of the project. The schedule is either a duration
+// ("15m", "24h") or a five-field cron expression ("*/15 20-23 * * 1-5").
+func New(project *models.Project) (*Schedule, error) {
+	s := &Schedule{}
+
+	if project.Schedule == "" {
+		return nil, errors.New("schedule is empty")
+	}
+	if d, err := time.ParseDuration(project.Schedule); err == nil {
+		if d <= 0 {
+			return nil, fmt.Errorf("schedule interval %q must be positive", project.Schedule)
+		}
+		s.interval = d
+	} else {
+		cron, err := parseCron(project.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("schedule is neither a duration nor a cron expression: %w", err)
+		}
+		s.cron = cron
+	}
+
+	for _, value := range project.ScheduleWindows {
+		from, to, found := strings.Cut(value, "-")
+		if !found {
+			return nil, fmt.Errorf("schedule window %q must look like 20:00-06:00", value)
+		}
+		fromMinutes, err := parseClock(from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule window %q: %w", value, err)
+		}
+		toMinutes, err := parseClock(to)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule window %q: %w", value, err)
+		}
+		if fromMinutes == toMinutes {
+			return nil, fmt.Errorf("schedule window %q is empty", value)
+		}
+		s.windows = append(s.windows, window{from: fromMinutes, to: toMinutes})
+	}
+
+	for _, blackout := range project.ScheduleBlackouts {
+		from, err := time.ParseInLocation(blackoutLayout, blackout.From, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("invalid blackout start %q: %w", blackout.From, err)
+		}
+		to, err := time.ParseInLocation(blackoutLayout, blackout.To, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("invalid blackout end %q: %w", blackout.To, err)
+		}
+		if !to.After(from) {
+			return nil, fmt.Errorf("blackout end %q must be after start %q", blackout.To, blackout.From)
+		}
+		s.blackouts = append(s.blackouts, period{from: from, to: to})
+	}
+
+	if project.ScheduleJitter != "" {
+		jitter, err := time.ParseDuration(project.ScheduleJitter)
+		if err != nil || jitter < 0 {
+			return nil, fmt.Errorf("invalid schedule jitter %q", project.ScheduleJitter)
+		}
+		s.jitter = jitter
+	}
+
+	return s, nil
+}
+
+func parseClock(value string) (int, error) {
+	t, err := time.Parse(clockLayout, strings.TrimSpace(value))
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// RunsAtStart reports whether the project runs immediately on start, as interval schedules always did.
+// Cron schedules run only at matching times.
+func (s *Schedule) RunsAtStart() bool {
+	return s.cron == nil
+}
+
+// Allowed reports whether a run may start at t according to windows and blackouts.
+// A window allows its first minute but not its last one: "20:00-06:00" ends at 05:59.
+func (s *Schedule) Allowed(t time.Time) bool {
+	for _, p := range s.blackouts {
+		if !t.Before(p.from) && t.Before(p.to) {
+			return false
+		}
+	}
+
+	if len(s.windows) == 0 {
+		return true
+	}
+	minutes := t.Hour()*60 + t.Minute()
+	for _, w := range s.windows {
+		if w.from < w.to {
+			if minutes >= w.from && minutes < w.to {
+				return true
+			}
+		} else if minutes >= w.from || minutes < w.to {
+			return true
+		}
+	}
+	return false
+}
+
+// Next returns the next planned run after the given time, or the zero time if none exists.
+func (s *Schedule) Next(after time.Time) time.Time {
+	var next time.Time
+	if s.cron != nil {
+		next = s.nextMinute(after.Truncate(time.Minute).Add(time.Minute), s.cron.matches)
+	} else {
+		next = after.Add(s.interval)
+		if !s.Allowed(next) {
+			next = s.nextMinute(next.Truncate(time.Minute).Add(time.Minute), func(time.Time) bool { return true })
+		}
+	}
+
+	if next.IsZero() || s.jitter <= 0 {
+		return next
+	}
+	if jittered := next.Add(rand.N(s.jitter)); s.Allowed(jittered) {
+		return jittered
+	}
+	return next
+}
+
+// nextMinute finds the first allowed minute starting from t that satisfies match.
+func (s *Schedule) nextMinute(t time.Time, match func(time.Time) bool) time.Time {
+	for limit := t.Add(searchLimit); t.Before(limit); t = t.Add(time.Minute) {
+		if match(t) && s.Allowed(t) {
+			return t
+		}
+	}
+	return time.Time{}
+}
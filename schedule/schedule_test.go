@@ -0,0 +1,192 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"storage_to_git/models"
+)
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		project models.Project
+	}{
+		{"empty schedule", models.Project{}},
+		{"negative interval", models.Project{Schedule: "-5m"}},
+		{"zero interval", models.Project{Schedule: "0s"}},
+		{"neither interval nor cron", models.Project{Schedule: "hourly"}},
+		{"window without end", models.Project{Schedule: "1h", ScheduleWindows: []string{"20:00"}}},
+		{"window with invalid time", models.Project{Schedule: "1h", ScheduleWindows: []string{"25:00-06:00"}}},
+		{"empty window", models.Project{Schedule: "1h", ScheduleWindows: []string{"06:00-06:00"}}},
+		{"blackout with invalid start", models.Project{Schedule: "1h", ScheduleBlackouts: []models.Blackout{{From: "2024-03-01", To: "2024-03-02 10:00"}}}},
+		{"blackout ending before start", models.Project{Schedule: "1h", ScheduleBlackouts: []models.Blackout{{From: "2024-03-02 10:00", To: "2024-03-01 10:00"}}}},
+		{"negative jitter", models.Project{Schedule: "1h", ScheduleJitter: "-1m"}},
+		{"invalid jitter", models.Project{Schedule: "1h", ScheduleJitter: "soon"}},
+	}
+	for _, tt := range tests {
+		if _, err := New(&tt.project); err == nil {
+			t.Errorf("%s: New succeeded, want an error", tt.name)
+		}
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	s, err := New(&models.Project{
+		Schedule:          "1h",
+		ScheduleWindows:   []string{"20:00-06:00", "12:00-13:00"},
+		ScheduleBlackouts: []models.Blackout{{From: "2024-03-01 22:00", To: "2024-03-02 01:30"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		at   string
+		want bool
+	}{
+		{"2024-03-05 19:59", false},
+		{"2024-03-05 20:00", true},
+		{"2024-03-05 23:59", true},
+		{"2024-03-06 00:00", true},
+		{"2024-03-06 05:59", true},
+		{"2024-03-06 06:00", false}, // the end of a window is excluded
+		{"2024-03-06 11:59", false},
+		{"2024-03-06 12:00", true},
+		{"2024-03-06 12:59", true},
+		{"2024-03-06 13:00", false},
+		{"2024-03-01 21:59", true},
+		{"2024-03-01 22:00", false}, // blackout, although inside the window
+		{"2024-03-02 01:29", false},
+		{"2024-03-02 01:30", true}, // the end of a blackout is excluded too
+	}
+	for _, tt := range tests {
+		at, err := time.ParseInLocation(blackoutLayout, tt.at, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := s.Allowed(at); got != tt.want {
+			t.Errorf("Allowed(%s) = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+
+	if always, _ := New(&models.Project{Schedule: "1h"}); !always.Allowed(time.Now()) {
+		t.Error("a schedule without windows and blackouts must always allow runs")
+	}
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		name    string
+		project models.Project
+		after   string
+		want    string // "" means no next run
+	}{
+		{
+			name:    "interval",
+			project: models.Project{Schedule: "15m"},
+			after:   "2024-03-01 10:07",
+			want:    "2024-03-01 10:22",
+		},
+		{
+			name:    "interval moved to the start of the next window",
+			project: models.Project{Schedule: "1h", ScheduleWindows: []string{"20:00-06:00"}},
+			after:   "2024-03-01 05:30",
+			want:    "2024-03-01 20:00",
+		},
+		{
+			name:    "interval inside a window wrapping midnight",
+			project: models.Project{Schedule: "1h", ScheduleWindows: []string{"20:00-06:00"}},
+			after:   "2024-03-01 23:30",
+			want:    "2024-03-02 00:30",
+		},
+		{
+			name:    "interval after a blackout",
+			project: models.Project{Schedule: "30m", ScheduleBlackouts: []models.Blackout{{From: "2024-03-01 10:00", To: "2024-03-01 12:00"}}},
+			after:   "2024-03-01 09:45",
+			want:    "2024-03-01 12:00",
+		},
+		{
+			name:    "cron",
+			project: models.Project{Schedule: "0 3 * * *"},
+			after:   "2024-03-01 03:00",
+			want:    "2024-03-02 03:00",
+		},
+		{
+			name:    "cron on weekdays",
+			project: models.Project{Schedule: "30 18 * * 1-5"},
+			after:   "2024-03-01 19:00", // Friday
+			want:    "2024-03-04 18:30",
+		},
+		{
+			name:    "cron skips a blackout",
+			project: models.Project{Schedule: "0 3 * * *", ScheduleBlackouts: []models.Blackout{{From: "2024-03-02 00:00", To: "2024-03-03 00:00"}}},
+			after:   "2024-03-01 12:00",
+			want:    "2024-03-03 03:00",
+		},
+		{
+			name:    "cron outside every window",
+			project: models.Project{Schedule: "0 3 * * *", ScheduleWindows: []string{"01:00-02:00"}},
+			after:   "2024-03-01 12:00",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := New(&tt.project)
+			if err != nil {
+				t.Fatal(err)
+			}
+			after, err := time.ParseInLocation(blackoutLayout, tt.after, time.Local)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := s.Next(after)
+			if tt.want == "" {
+				if !got.IsZero() {
+					t.Errorf("Next(%s) = %s, want none", tt.after, got.Format(blackoutLayout))
+				}
+				return
+			}
+			if got.Format(blackoutLayout) != tt.want {
+				t.Errorf("Next(%s) = %s, want %s", tt.after, got.Format(blackoutLayout), tt.want)
+			}
+		})
+	}
+}
+
+func TestNextJitter(t *testing.T) {
+	s, err := New(&models.Project{Schedule: "10m", ScheduleJitter: "5m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := time.Date(2024, 3, 1, 10, 0, 0, 0, time.Local)
+	jittered := false
+	for range 100 {
+		next := s.Next(after)
+		if next.Before(after.Add(10*time.Minute)) || !next.Before(after.Add(15*time.Minute)) {
+			t.Fatalf("Next = %s, want within [10:10, 10:15)", next.Format(time.TimeOnly))
+		}
+		jittered = jittered || !next.Equal(after.Add(10*time.Minute))
+	}
+	if !jittered {
+		t.Error("jitter never applied")
+	}
+
+	// Jitter never moves a run out of its window.
+	s, err = New(&models.Project{Schedule: "10m", ScheduleJitter: "1h", ScheduleWindows: []string{"10:00-10:11"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 100 {
+		if next := s.Next(after); !s.Allowed(next) {
+			t.Fatalf("Next = %s is outside the window", next.Format(time.TimeOnly))
+		}
+	}
+}
+
+func TestRunsAtStart(t *testing.T) {
+	interval, _ := New(&models.Project{Schedule: "1h"})
+	cron, _ := New(&models.Project{Schedule: "0 * * * *"})
+	if !interval.RunsAtStart() || cron.RunsAtStart() {
+		t.Errorf("RunsAtStart: interval %v, cron %v; want true, false", interval.RunsAtStart(), cron.RunsAtStart())
+	}
+}
@@ -0,0 +1,111 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	keystoreVersion = 1
+	kdfIterations   = 600000
+	keySize         = 32
+	saltSize        = 16
+)
+
+// keystoreFile is the on-disk keystore: a JSON map of secrets encrypted with AES-256-GCM
+// under a key derived from the master key with PBKDF2-SHA256.
+type keystoreFile struct {
+	Version    int    `json:"version"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"`
+}
+
+// LoadKeystore decrypts the keystore file. A missing file is reported as an error.
+func LoadKeystore(path, masterKey string) (map[string]string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keystore: %w", err)
+	}
+
+	var file keystoreFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse keystore %s: %w", path, err)
+	}
+	if file.Version != keystoreVersion {
+		return nil, fmt.Errorf("unsupported keystore version %d", file.Version)
+	}
+
+	gcm, err := newCipher(masterKey, file.Salt, file.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, file.Nonce, file.Data, nil)
+	if err != nil {
+		return nil, errors.New("failed to decrypt keystore: wrong master key or corrupted file")
+	}
+
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse keystore secrets: %w", err)
+	}
+	return secrets, nil
+}
+
+// SaveKeystore encrypts the secrets with a fresh salt and nonce and writes the keystore file.
+func SaveKeystore(path, masterKey string, secrets map[string]string) error {
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+
+	file := keystoreFile{Version: keystoreVersion, Iterations: kdfIterations, Salt: make([]byte, saltSize)}
+	if _, err := rand.Read(file.Salt); err != nil {
+		return err
+	}
+	gcm, err := newCipher(masterKey, file.Salt, file.Iterations)
+	if err != nil {
+		return err
+	}
+	file.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return err
+	}
+	file.Data = gcm.Seal(nil, file.Nonce, plain, nil)
+
+	raw, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create keystore directory: %w", err)
+	}
+	if err := os.WriteFile(path, raw, 0600); err != nil {
+		return fmt.Errorf("failed to write keystore: %w", err)
+	}
+	return nil
+}
+
+func newCipher(masterKey string, salt []byte, iterations int) (cipher.AEAD, error) {
+	if iterations <= 0 {
+		return nil, fmt.Errorf("invalid keystore iterations %d", iterations)
+	}
+	key, err := pbkdf2.Key(sha256.New, masterKey, salt, iterations, keySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive keystore key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
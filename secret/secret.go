@@ -0,0 +1,168 @@
+package secret
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"storage_to_git/models"
+)
+
+const (
+	envPrefix      = "env:"
+	filePrefix     = "file:"
+	keystorePrefix = "keystore:"
+
+	// DefaultMasterKey is used when the config does not set keystore_master_key.
+	DefaultMasterKey = "env:STORAGE_TO_GIT_MASTER_KEY"
+)
+
+// Resolver turns secret references into values. A value may be a plain password or one of
+// "env:NAME", "file:/path" or "keystore:NAME". The keystore is opened on first use.
+type Resolver struct {
+	baseDir      string
+	keystorePath string
+	masterKey    string
+	keystore     map[string]string
+}
+
+// NewResolver returns a resolver for the keystore settings of the config. Relative keystore
+// and secret file paths are resolved against baseDir.
+func NewResolver(config *models.Config, baseDir string) *Resolver {
+	r := &Resolver{baseDir: baseDir, keystorePath: config.KeystorePath, masterKey: config.KeystoreMasterKey}
+	if r.keystorePath != "" && !filepath.IsAbs(r.keystorePath) {
+		r.keystorePath = filepath.Join(baseDir, r.keystorePath)
+	}
+	if r.masterKey == "" {
+		r.masterKey = DefaultMasterKey
+	}
+	return r
+}
+
+// Resolve returns the value a reference points to. Values without a known prefix are returned as is.
+func (r *Resolver) Resolve(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, envPrefix):
+		return resolveEnv(strings.TrimPrefix(value, envPrefix))
+	case strings.HasPrefix(value, filePrefix):
+		path := strings.TrimPrefix(value, filePrefix)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(r.baseDir, path)
+		}
+		return resolveFile(path)
+	case strings.HasPrefix(value, keystorePrefix):
+		name := strings.TrimPrefix(value, keystorePrefix)
+		keystore, err := r.Keystore()
+		if err != nil {
+			return "", err
+		}
+		secret, ok := keystore[name]
+		if !ok {
+			return "", fmt.Errorf("secret %q not found in keystore %s", name, r.keystorePath)
+		}
+		return secret, nil
+	}
+	return value, nil
+}
+
+// Keystore opens the keystore with the master key and returns its secrets.
+func (r *Resolver) Keystore() (map[string]string, error) {
+	if r.keystore != nil {
+		return r.keystore, nil
+	}
+	masterKey, err := r.MasterKey()
+	if err != nil {
+		return nil, err
+	}
+	keystore, err := LoadKeystore(r.keystorePath, masterKey)
+	if err != nil {
+		return nil, err
+	}
+	r.keystore = keystore
+	return keystore, nil
+}
+
+// KeystorePath returns the absolute path of the keystore file.
+func (r *Resolver) KeystorePath() string {
+	return r.keystorePath
+}
+
+// MasterKey resolves the master key reference. The key itself may not point to the keystore.
+func (r *Resolver) MasterKey() (string, error) {
+	if r.keystorePath == "" {
+		return "", fmt.Errorf("keystore_path is not set")
+	}
+	if strings.HasPrefix(r.masterKey, keystorePrefix) {
+		return "", fmt.Errorf("keystore_master_key cannot refer to the keystore")
+	}
+	masterKey, err := r.Resolve(r.masterKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve keystore master key: %w", err)
+	}
+	if masterKey == "" {
+		return "", fmt.Errorf("keystore master key is empty")
+	}
+	return masterKey, nil
+}
+
+func resolveEnv(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
+
+func resolveFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// ResolveConfig replaces secret references in the passwords of all projects with their values.
+func ResolveConfig(config *models.Config, baseDir string) error {
+	r := NewResolver(config, baseDir)
+
+	resolve := func(path string, value *string) error {
+		resolved, err := r.Resolve(*value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		*value = resolved
+		return nil
+	}
+
+	for i := range config.Projects {
+		project := &config.Projects[i]
+		prefix := fmt.Sprintf("projects[%d]", i)
+
+		if err := resolve(prefix+".infobase.infobase_password", &project.InfoBase.InfoBasePassword); err != nil {
+			return err
+		}
+		if project.ClusterAdmin != nil {
+			if err := resolve(prefix+".cluster_admin.cluster_password", &project.ClusterAdmin.ClusterPassword); err != nil {
+				return err
+			}
+		}
+		if project.Storage != nil {
+			if err := resolve(prefix+".storage.storage_password", &project.Storage.StoragePassword); err != nil {
+				return err
+			}
+		}
+		if project.GitSigning != nil {
+			if err := resolve(prefix+".git_signing.passphrase", &project.GitSigning.Passphrase); err != nil {
+				return err
+			}
+		}
+		for j := range project.Extensions {
+			path := fmt.Sprintf("%s.extensions[%d].storage_password", prefix, j)
+			if err := resolve(path, &project.Extensions[j].StoragePassword); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
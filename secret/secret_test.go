@@ -0,0 +1,100 @@
+package secret
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"storage_to_git/models"
+)
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "storage.txt"), []byte("from-file\r\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "secrets"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "secrets", "relative.txt"), []byte("relative\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveKeystore(filepath.Join(dir, "secrets", "keystore.json"), "master", map[string]string{"erp": "from-keystore"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("S2G_TEST_PASSWORD", "from-env")
+	t.Setenv("S2G_TEST_MASTER", "master")
+
+	r := NewResolver(&models.Config{KeystorePath: "secrets/keystore.json", KeystoreMasterKey: "env:S2G_TEST_MASTER"}, dir)
+	tests := []struct {
+		value, want string
+	}{
+		{"plain", "plain"},
+		{"", ""},
+		{"env:S2G_TEST_PASSWORD", "from-env"},
+		{"file:" + filepath.Join(dir, "storage.txt"), "from-file"},
+		{"file:secrets/relative.txt", "relative"},
+		{"keystore:erp", "from-keystore"},
+	}
+	for _, tt := range tests {
+		got, err := r.Resolve(tt.value)
+		if err != nil {
+			t.Errorf("Resolve(%q): %v", tt.value, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Resolve(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+
+	for _, value := range []string{"env:S2G_TEST_MISSING", "file:missing.txt", "keystore:missing"} {
+		if _, err := r.Resolve(value); err == nil {
+			t.Errorf("Resolve(%q) succeeded, want an error", value)
+		}
+	}
+}
+
+func TestResolveConfigReportsField(t *testing.T) {
+	config := &models.Config{Projects: []models.Project{
+		{Name: "erp", Storage: &models.Storage{StoragePassword: "env:S2G_TEST_MISSING"}},
+	}}
+	err := ResolveConfig(config, t.TempDir())
+	if err == nil || !strings.HasPrefix(err.Error(), "projects[0].storage.storage_password:") {
+		t.Fatalf("ResolveConfig error = %v, want one naming the field", err)
+	}
+}
+
+func TestLoadKeystoreRejectsWrongKeyAndTampering(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keystore.json")
+	if err := SaveKeystore(path, "master", map[string]string{"erp": "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	if secrets, err := LoadKeystore(path, "master"); err != nil || secrets["erp"] != "secret" {
+		t.Fatalf("LoadKeystore = %v, %v", secrets, err)
+	}
+
+	if _, err := LoadKeystore(path, "wrong"); err == nil || !strings.Contains(err.Error(), "wrong master key") {
+		t.Errorf("LoadKeystore with a wrong key: %v", err)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file keystoreFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		t.Fatal(err)
+	}
+	file.Data[0] ^= 1
+	if raw, err = json.Marshal(file); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, raw, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKeystore(path, "master"); err == nil || !strings.Contains(err.Error(), "corrupted") {
+		t.Errorf("LoadKeystore of a tampered file: %v", err)
+	}
+}
@@ -42,9 +42,9 @@ func LoadUserMappings(filePath string) ([]models.UserMapping, error) {
 		}
 
 		mapping := models.UserMapping{
-			StorageUser:   record[0],
-			GitUser:  record[1],
-			GitEmail: record[2],
+			StorageUser: record[0],
+			GitUser:     record[1],
+			GitEmail:    record[2],
 		}
 		mappings = append(mappings, mapping)
 	}
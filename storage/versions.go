@@ -3,12 +3,21 @@ package storage
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
 	"os"
+	"path/filepath"
 
 	"storage_to_git/models"
 )
 
-func LoadOrInitVersions(filePath string, project models.Project) (models.VersionMap, error) {
+// ErrVersionsCorrupt is returned when neither the versions file nor its backup can be read.
+var ErrVersionsCorrupt = errors.New("versions file is corrupt")
+
+// backupSuffix is appended to the versions file name for the copy of the previous state.
+const backupSuffix = ".bak"
+
+func LoadOrInitVersions(logger *slog.Logger, filePath string, project models.Project) (models.VersionMap, error) {
 	_, err := os.Stat(filePath)
 	if errors.Is(err, os.ErrNotExist) {
 		versions := make(models.VersionMap)
@@ -28,6 +37,48 @@ func LoadOrInitVersions(filePath string, project models.Project) (models.Version
 		return versions, nil
 	}
 
+	return LoadVersions(logger, filePath)
+}
+
+// SaveVersions replaces the versions file atomically: the new state is written to a temporary
+// file, synced and renamed over the old one, which is kept as a backup first.
+func SaveVersions(filePath string, versions models.VersionMap) error {
+	data, err := json.MarshalIndent(versions, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if previous, err := os.ReadFile(filePath); err == nil && json.Valid(previous) {
+		if err := writeFileAtomic(filePath+backupSuffix, previous); err != nil {
+			return fmt.Errorf("failed to back up versions file: %w", err)
+		}
+	}
+
+	return writeFileAtomic(filePath, data)
+}
+
+// LoadVersions reads the versions file without creating it. A missing file yields an empty map.
+// If the file is unreadable, the backup of the previous state is used; if that fails too,
+// the error wraps ErrVersionsCorrupt.
+func LoadVersions(logger *slog.Logger, filePath string) (models.VersionMap, error) {
+	versions, err := readVersions(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return make(models.VersionMap), nil
+	}
+	if err == nil {
+		return versions, nil
+	}
+
+	logger.Warn("Versions file is unreadable, trying backup", "path", filePath, "error", err)
+	versions, backupErr := readVersions(filePath + backupSuffix)
+	if backupErr != nil {
+		return nil, fmt.Errorf("%w: %s: %v; backup: %v", ErrVersionsCorrupt, filePath, err, backupErr)
+	}
+	logger.Warn("Versions restored from backup", "path", filePath+backupSuffix, "versions", versions)
+	return versions, nil
+}
+
+func readVersions(filePath string) (models.VersionMap, error) {
 	file, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, err
@@ -38,16 +89,46 @@ func LoadOrInitVersions(filePath string, project models.Project) (models.Version
 	if err != nil {
 		return nil, err
 	}
+	if versions == nil {
+		return nil, errors.New("versions file is empty")
+	}
 
 	return versions, nil
 }
 
-func SaveVersions(filePath string, versions models.VersionMap) error {
-	data, err := json.MarshalIndent(versions, "", "  ")
+// writeFileAtomic writes data to a temporary file in the same directory, syncs it and renames it
+// over filePath, so readers see either the old or the new content.
+func writeFileAtomic(filePath string, data []byte) error {
+	dir := filepath.Dir(filePath)
+	tmp, err := os.CreateTemp(dir, filepath.Base(filePath)+".tmp*")
 	if err != nil {
 		return err
 	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
 
-	return os.WriteFile(filePath, data, 0644)
-}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		return err
+	}
 
+	// Persist the rename itself. Directories cannot be synced on Windows, which is not an error.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"storage_to_git/models"
+)
+
+func TestSaveVersionsKeepsBackup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "versions.json")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	first := models.VersionMap{"cf": 5, "Sales": 2}
+	second := models.VersionMap{"cf": 6, "Sales": 2}
+	if err := SaveVersions(path, first); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveVersions(path, second); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := LoadVersions(logger, path); err != nil || !reflect.DeepEqual(got, second) {
+		t.Errorf("LoadVersions = %v, %v; want %v", got, err, second)
+	}
+	if got, err := readVersions(path + backupSuffix); err != nil || !reflect.DeepEqual(got, first) {
+		t.Errorf("backup = %v, %v; want %v", got, err, first)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if want := []string{"versions.json", "versions.json.bak"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("directory holds %q, want %q: temporary files must be renamed or removed", names, want)
+	}
+}
+
+func TestLoadVersions(t *testing.T) {
+	backup := models.VersionMap{"cf": 5}
+	tests := []struct {
+		name         string
+		main, backup string // "" means the file does not exist
+		want         models.VersionMap
+		wantCorrupt  bool
+	}{
+		{name: "missing file", want: models.VersionMap{}},
+		{name: "valid file", main: `{"cf": 7}`, backup: `{"cf": 5}`, want: models.VersionMap{"cf": 7}},
+		{name: "truncated file", main: `{"cf": 7, "Sal`, backup: `{"cf": 5}`, want: backup},
+		{name: "empty file", main: " ", backup: `{"cf": 5}`, want: backup},
+		{name: "null file", main: "null", backup: `{"cf": 5}`, want: backup},
+		{name: "corrupt file and backup", main: "\x00\x00", backup: `{"cf"`, wantCorrupt: true},
+		{name: "corrupt file without backup", main: `[1, 2]`, wantCorrupt: true},
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "versions.json")
+			for file, content := range map[string]string{path: tt.main, path + backupSuffix: tt.backup} {
+				if content == "" {
+					continue
+				}
+				if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := LoadVersions(logger, path)
+			if tt.wantCorrupt {
+				if !errors.Is(err, ErrVersionsCorrupt) {
+					t.Fatalf("LoadVersions error = %v, want ErrVersionsCorrupt", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadVersions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaveVersionsDoesNotBackUpCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "versions.json")
+	for _, version := range []int{5, 6} {
+		if err := SaveVersions(path, models.VersionMap{"cf": version}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(path, []byte(`{"cf": 6`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveVersions(path, models.VersionMap{"cf": 7}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := readVersions(path + backupSuffix); err != nil || got["cf"] != 5 {
+		t.Errorf("backup = %v, %v; want the last valid state", got, err)
+	}
+}
@@ -0,0 +1,39 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// lockFileName is the lock file in the data directory of a project.
+const lockFileName = ".lock"
+
+// ErrLocked is returned by Lock when another process or run holds the lock of the project.
+var ErrLocked = errors.New("project is locked by another run or rebuild")
+
+// Lock keeps the service runs, the run subcommand and rebuilds of a project from sharing the
+// service infobase, the repository reports, the 1C log and the git working tree.
+// The operating system releases it when the process exits.
+type Lock struct {
+	file *os.File
+}
+
+// Lock takes the lock of the project without waiting.
+func (w Workspace) Lock() (*Lock, error) {
+	if err := os.MkdirAll(w.Dir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create project data directory: %w", err)
+	}
+	path := filepath.Join(w.Dir, lockFileName)
+	file, err := lockFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Lock{file: file}, nil
+}
+
+// Unlock releases the lock.
+func (l *Lock) Unlock() error {
+	return l.file.Close()
+}
@@ -0,0 +1,29 @@
+//go:build windows
+
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// errorSharingViolation is returned by CreateFile for a file another handle opened without sharing.
+const errorSharingViolation syscall.Errno = 32
+
+// lockFile opens the file without sharing, so no other handle can open it until it is closed.
+func lockFile(path string) (*os.File, error) {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := syscall.CreateFile(name, syscall.GENERIC_READ|syscall.GENERIC_WRITE, 0, nil, syscall.OPEN_ALWAYS, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+	if err != nil {
+		if errors.Is(err, errorSharingViolation) {
+			return nil, fmt.Errorf("%w: %s", ErrLocked, path)
+		}
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	return os.NewFile(uintptr(handle), path), nil
+}
@@ -0,0 +1,149 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"storage_to_git/models"
+)
+
+const (
+	reportExt     = ".report"
+	dumpResultExt = ".dump"
+)
+
+// Workspace owns the paths of the files a project keeps in its project_data_path: repository
+// reports, the versions file, the users file, the 1C log and the /DumpResult file.
+// File settings are relative to the data directory unless they are absolute.
+type Workspace struct {
+	Dir string
+
+	// legacyDir is where earlier releases looked for some files: the parent of project_data_path
+	// when the path had no trailing separator.
+	legacyDir    string
+	versionsFile string
+	usersFile    string
+	logFile      string
+}
+
+func New(project *models.Project) Workspace {
+	dir := filepath.Clean(project.ProjectDataPath)
+	return Workspace{
+		Dir:          dir,
+		legacyDir:    filepath.Dir(project.ProjectDataPath),
+		versionsFile: project.VersionsFilePath,
+		usersFile:    project.UsersFilePath,
+		logFile:      project.V8LogFilePath,
+	}
+}
+
+func (w Workspace) path(name string) string {
+	if filepath.IsAbs(name) {
+		return filepath.Clean(name)
+	}
+	return filepath.Join(w.Dir, name)
+}
+
+// VersionsFile is the file with the last processed version per version key.
+func (w Workspace) VersionsFile() string {
+	return w.path(w.versionsFile)
+}
+
+// UsersFile is the CSV file mapping storage users to git authors.
+func (w Workspace) UsersFile() string {
+	return w.path(w.usersFile)
+}
+
+// LogFile is the file the 1C platform writes its messages to (/OUT).
+func (w Workspace) LogFile() string {
+	return w.path(w.logFile)
+}
+
+// DumpResultFile is the file the 1C platform writes its result code to (/DumpResult).
+func (w Workspace) DumpResultFile() string {
+	return DumpResultPath(w.LogFile())
+}
+
+// ReportFile is the repository report of a version key: "cf" or an extension name.
+func (w Workspace) ReportFile(key string) string {
+	return filepath.Join(w.Dir, key+reportExt)
+}
+
+// ReportKey returns the version key of a report file.
+func ReportKey(reportFile string) string {
+	return strings.TrimSuffix(filepath.Base(reportFile), reportExt)
+}
+
+// DumpResultPath returns the /DumpResult file that belongs to a 1C log file.
+func DumpResultPath(logFile string) string {
+	return strings.TrimSuffix(logFile, filepath.Ext(logFile)) + dumpResultExt
+}
+
+// UsersFileToRead returns the users file, or the file Migrate would copy to it if the users file
+// does not exist yet. Commands that must not change the workspace read the users file from here.
+func (w Workspace) UsersFileToRead() string {
+	current := w.UsersFile()
+	legacy := w.legacyUsersFile()
+	if legacy == "" {
+		return current
+	}
+	if _, err := os.Stat(current); errors.Is(err, os.ErrNotExist) {
+		if _, err := os.Stat(legacy); err == nil {
+			return legacy
+		}
+	}
+	return current
+}
+
+// legacyUsersFile returns where earlier releases read the users file, or "" if it was read from
+// the users file path.
+func (w Workspace) legacyUsersFile() string {
+	if filepath.IsAbs(w.usersFile) || filepath.Clean(w.legacyDir) == w.Dir {
+		return ""
+	}
+	return filepath.Join(w.legacyDir, w.usersFile)
+}
+
+// Migrate copies the users file from the location used by earlier releases, which read it from
+// the parent of project_data_path when the path had no trailing separator. The file is copied,
+// not moved, because several projects may share that directory.
+func (w Workspace) Migrate(logger *slog.Logger) error {
+	legacy := w.legacyUsersFile()
+	if legacy == "" {
+		return nil
+	}
+
+	current := w.UsersFile()
+	if _, err := os.Stat(current); !errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	data, err := os.ReadFile(legacy)
+	if err != nil {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(current), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create project data directory: %w", err)
+	}
+	// O_EXCL keeps a users file created meanwhile from being overwritten.
+	file, err := os.OpenFile(current, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, os.ErrExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to copy users file to project data directory: %w", err)
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to copy users file to project data directory: %w", err)
+	}
+	logger.Warn("Users file copied to project data directory", "from", legacy, "to", current)
+	return nil
+}
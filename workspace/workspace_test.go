@@ -0,0 +1,195 @@
+package workspace
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"storage_to_git/models"
+)
+
+func TestPaths(t *testing.T) {
+	root := t.TempDir()
+	abs := filepath.Join(root, "shared")
+	sep := string(filepath.Separator)
+
+	tests := []struct {
+		name     string
+		project  models.Project
+		dir      string
+		versions string
+		users    string
+		log      string
+		dump     string
+		report   string
+	}{
+		{
+			name:     "absolute without trailing separator",
+			project:  models.Project{ProjectDataPath: filepath.Join(root, "p1"), VersionsFilePath: "versions.json", UsersFilePath: "users.csv", V8LogFilePath: "log.txt"},
+			dir:      filepath.Join(root, "p1"),
+			versions: filepath.Join(root, "p1", "versions.json"),
+			users:    filepath.Join(root, "p1", "users.csv"),
+			log:      filepath.Join(root, "p1", "log.txt"),
+			dump:     filepath.Join(root, "p1", "log.dump"),
+			report:   filepath.Join(root, "p1", "cf.report"),
+		},
+		{
+			name:     "absolute with trailing separator",
+			project:  models.Project{ProjectDataPath: filepath.Join(root, "p1") + sep, VersionsFilePath: "versions.json", UsersFilePath: "users.csv", V8LogFilePath: "log.txt"},
+			dir:      filepath.Join(root, "p1"),
+			versions: filepath.Join(root, "p1", "versions.json"),
+			users:    filepath.Join(root, "p1", "users.csv"),
+			log:      filepath.Join(root, "p1", "log.txt"),
+			dump:     filepath.Join(root, "p1", "log.dump"),
+			report:   filepath.Join(root, "p1", "cf.report"),
+		},
+		{
+			name:     "relative without trailing separator",
+			project:  models.Project{ProjectDataPath: filepath.Join("data", "p2"), VersionsFilePath: "versions.json", UsersFilePath: "users.csv", V8LogFilePath: filepath.Join("logs", "1c.log")},
+			dir:      filepath.Join("data", "p2"),
+			versions: filepath.Join("data", "p2", "versions.json"),
+			users:    filepath.Join("data", "p2", "users.csv"),
+			log:      filepath.Join("data", "p2", "logs", "1c.log"),
+			dump:     filepath.Join("data", "p2", "logs", "1c.dump"),
+			report:   filepath.Join("data", "p2", "cf.report"),
+		},
+		{
+			name:     "relative with trailing separator",
+			project:  models.Project{ProjectDataPath: "." + sep + filepath.Join("data", "p2") + sep, VersionsFilePath: "versions.json", UsersFilePath: "users.csv", V8LogFilePath: "log.txt"},
+			dir:      filepath.Join("data", "p2"),
+			versions: filepath.Join("data", "p2", "versions.json"),
+			users:    filepath.Join("data", "p2", "users.csv"),
+			log:      filepath.Join("data", "p2", "log.txt"),
+			dump:     filepath.Join("data", "p2", "log.dump"),
+			report:   filepath.Join("data", "p2", "cf.report"),
+		},
+		{
+			name: "absolute file settings",
+			project: models.Project{
+				ProjectDataPath:  filepath.Join("data", "p3") + sep,
+				VersionsFilePath: filepath.Join(abs, "versions.json"),
+				UsersFilePath:    filepath.Join(abs, "users.csv"),
+				V8LogFilePath:    filepath.Join(abs, "logs", "..", "1c.log"),
+			},
+			dir:      filepath.Join("data", "p3"),
+			versions: filepath.Join(abs, "versions.json"),
+			users:    filepath.Join(abs, "users.csv"),
+			log:      filepath.Join(abs, "1c.log"),
+			dump:     filepath.Join(abs, "1c.dump"),
+			report:   filepath.Join("data", "p3", "cf.report"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := New(&tt.project)
+			for _, check := range []struct{ name, got, want string }{
+				{"Dir", ws.Dir, tt.dir},
+				{"VersionsFile", ws.VersionsFile(), tt.versions},
+				{"UsersFile", ws.UsersFile(), tt.users},
+				{"LogFile", ws.LogFile(), tt.log},
+				{"DumpResultFile", ws.DumpResultFile(), tt.dump},
+				{"ReportFile", ws.ReportFile("cf"), tt.report},
+			} {
+				if check.got != check.want {
+					t.Errorf("%s = %q, want %q", check.name, check.got, check.want)
+				}
+			}
+			if key := ReportKey(ws.ReportFile("sales")); key != "sales" {
+				t.Errorf("ReportKey = %q, want %q", key, "sales")
+			}
+		})
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sep := string(filepath.Separator)
+
+	tests := []struct {
+		name string
+		// dataPath is relative to the test root, usersFile too when not absolute.
+		dataPath  string
+		usersFile string
+		absUsers  bool
+		existing  string // content of a users file already in the data directory
+		want      string // expected content of the users file in the data directory, "" if none
+	}{
+		{name: "without trailing separator copies", dataPath: "p", usersFile: "users.csv", want: "legacy"},
+		{name: "with trailing separator leaves alone", dataPath: "p" + sep, usersFile: "users.csv"},
+		{name: "existing file is not overwritten", dataPath: "p", usersFile: "users.csv", existing: "current", want: "current"},
+		{name: "absolute users file leaves alone", dataPath: "p", usersFile: "users.csv", absUsers: true},
+		{name: "nested users file copies", dataPath: "p", usersFile: filepath.Join("cfg", "users.csv"), want: "legacy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			legacy := filepath.Join(root, tt.usersFile)
+			if err := os.MkdirAll(filepath.Dir(legacy), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(legacy, []byte("legacy"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			usersFile := tt.usersFile
+			if tt.absUsers {
+				usersFile = legacy
+			}
+			current := filepath.Join(root, "p", tt.usersFile)
+			if tt.existing != "" {
+				if err := os.MkdirAll(filepath.Dir(current), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(current, []byte(tt.existing), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Relative project_data_path is resolved against the working directory.
+			t.Chdir(root)
+			ws := New(&models.Project{ProjectDataPath: tt.dataPath, UsersFilePath: usersFile})
+			// Before the migration, the users file to read is the one Migrate makes the users file.
+			toRead, _ := os.ReadFile(ws.UsersFileToRead())
+			if err := ws.Migrate(logger); err != nil {
+				t.Fatalf("Migrate: %v", err)
+			}
+			if migrated, _ := os.ReadFile(ws.UsersFile()); string(toRead) != string(migrated) {
+				t.Errorf("UsersFileToRead has %q, users file after Migrate %q", toRead, migrated)
+			}
+
+			data, err := os.ReadFile(current)
+			switch {
+			case tt.want == "" && err == nil:
+				t.Errorf("users file %s created with %q", current, data)
+			case tt.want != "" && string(data) != tt.want:
+				t.Errorf("users file %s = %q, %v; want %q", current, data, err, tt.want)
+			}
+			if data, _ := os.ReadFile(legacy); string(data) != "legacy" {
+				t.Errorf("legacy users file changed to %q", data)
+			}
+		})
+	}
+}
+
+func TestLock(t *testing.T) {
+	ws := New(&models.Project{ProjectDataPath: filepath.Join(t.TempDir(), "data")})
+
+	lock, err := ws.Lock()
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	if _, err := ws.Lock(); !errors.Is(err, ErrLocked) {
+		t.Fatalf("second Lock = %v, want ErrLocked", err)
+	}
+	if err := lock.Unlock(); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+
+	lock, err = ws.Lock()
+	if err != nil {
+		t.Fatalf("Lock after Unlock: %v", err)
+	}
+	lock.Unlock()
+}